@@ -0,0 +1,108 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"log"
+
+	"github.com/go-gl/gl"
+)
+
+// Makes Program the current shader program. The previously used program is
+// queried on Enter and restored on Exit.
+// Example:
+//     With(UseProgram{prog}, func() { .. draw with prog .. })
+type UseProgram struct{ Program gl.Program }
+
+func (u UseProgram) Enter() {
+	pushState(getBinding(gl.CURRENT_PROGRAM))
+	u.Program.Use()
+}
+
+func (u UseProgram) Exit() {
+	gl.Program(popState().(uint32)).Use()
+}
+
+// Binds Buffer to Target. The buffer previously bound to Target is queried
+// on Enter and restored on Exit.
+type BindBuffer struct {
+	Target gl.GLenum
+	Buffer gl.Buffer
+}
+
+func (b BindBuffer) Enter() {
+	pushState(getBinding(bufferBindingFor(b.Target)))
+	b.Buffer.Bind(b.Target)
+}
+
+func (b BindBuffer) Exit() {
+	gl.Buffer(popState().(uint32)).Bind(b.Target)
+}
+
+// Binds a vertex array object. The previously bound VAO is queried on Enter
+// and restored on Exit.
+type BindVertexArray struct{ VertexArray gl.VertexArray }
+
+func (b BindVertexArray) Enter() {
+	pushState(getBinding(gl.VERTEX_ARRAY_BINDING))
+	b.VertexArray.Bind()
+}
+
+func (b BindVertexArray) Exit() {
+	gl.VertexArray(popState().(uint32)).Bind()
+}
+
+// getBinding returns the name of the object bound to the given binding point.
+func getBinding(pname gl.GLenum) uint32 {
+	var v [1]int32
+	gl.GetIntegerv(pname, v[:])
+	return uint32(v[0])
+}
+
+// bufferBindingFor returns the glGet enum which queries the buffer bound to
+// the given buffer target.
+func bufferBindingFor(target gl.GLenum) gl.GLenum {
+	switch target {
+	case gl.ARRAY_BUFFER:
+		return gl.ARRAY_BUFFER_BINDING
+	case gl.ELEMENT_ARRAY_BUFFER:
+		return gl.ELEMENT_ARRAY_BUFFER_BINDING
+	case gl.PIXEL_PACK_BUFFER:
+		return gl.PIXEL_PACK_BUFFER_BINDING
+	case gl.PIXEL_UNPACK_BUFFER:
+		return gl.PIXEL_UNPACK_BUFFER_BINDING
+	case gl.UNIFORM_BUFFER:
+		return gl.UNIFORM_BUFFER_BINDING
+	case gl.TEXTURE_BUFFER:
+		return gl.TEXTURE_BUFFER_BINDING
+	case gl.TRANSFORM_FEEDBACK_BUFFER:
+		return gl.TRANSFORM_FEEDBACK_BUFFER_BINDING
+	case gl.COPY_READ_BUFFER:
+		return gl.COPY_READ_BUFFER_BINDING
+	case gl.COPY_WRITE_BUFFER:
+		return gl.COPY_WRITE_BUFFER_BINDING
+	}
+	log.Panicf("glh: unsupported buffer target %x", target)
+	return 0
+}
+
+// textureBindingFor returns the glGet enum which queries the texture bound to
+// the given texture target.
+func textureBindingFor(target gl.GLenum) gl.GLenum {
+	switch target {
+	case gl.TEXTURE_2D:
+		return gl.TEXTURE_BINDING_2D
+	case gl.TEXTURE_CUBE_MAP:
+		return gl.TEXTURE_BINDING_CUBE_MAP
+	case gl.TEXTURE_2D_ARRAY:
+		return gl.TEXTURE_BINDING_2D_ARRAY
+	case gl.TEXTURE_3D:
+		return gl.TEXTURE_BINDING_3D
+	case gl.TEXTURE_CUBE_MAP_ARRAY:
+		return gl.TEXTURE_BINDING_CUBE_MAP_ARRAY
+	}
+	log.Panicf("glh: unsupported texture target %x", target)
+	return 0
+}
@@ -0,0 +1,102 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+func TestBindingFor(t *testing.T) {
+	tests := []struct {
+		Fn      func(gl.GLenum) gl.GLenum
+		In, Out gl.GLenum
+	}{
+		{bufferBindingFor, gl.ARRAY_BUFFER, gl.ARRAY_BUFFER_BINDING},
+		{bufferBindingFor, gl.ELEMENT_ARRAY_BUFFER, gl.ELEMENT_ARRAY_BUFFER_BINDING},
+		{bufferBindingFor, gl.PIXEL_PACK_BUFFER, gl.PIXEL_PACK_BUFFER_BINDING},
+		{bufferBindingFor, gl.PIXEL_UNPACK_BUFFER, gl.PIXEL_UNPACK_BUFFER_BINDING},
+		{bufferBindingFor, gl.UNIFORM_BUFFER, gl.UNIFORM_BUFFER_BINDING},
+		{bufferBindingFor, gl.TEXTURE_BUFFER, gl.TEXTURE_BUFFER_BINDING},
+		{bufferBindingFor, gl.TRANSFORM_FEEDBACK_BUFFER, gl.TRANSFORM_FEEDBACK_BUFFER_BINDING},
+		{bufferBindingFor, gl.COPY_READ_BUFFER, gl.COPY_READ_BUFFER_BINDING},
+		{bufferBindingFor, gl.COPY_WRITE_BUFFER, gl.COPY_WRITE_BUFFER_BINDING},
+		{textureBindingFor, gl.TEXTURE_2D, gl.TEXTURE_BINDING_2D},
+		{textureBindingFor, gl.TEXTURE_CUBE_MAP, gl.TEXTURE_BINDING_CUBE_MAP},
+		{textureBindingFor, gl.TEXTURE_2D_ARRAY, gl.TEXTURE_BINDING_2D_ARRAY},
+		{textureBindingFor, gl.TEXTURE_3D, gl.TEXTURE_BINDING_3D},
+		{textureBindingFor, gl.TEXTURE_CUBE_MAP_ARRAY, gl.TEXTURE_BINDING_CUBE_MAP_ARRAY},
+	}
+
+	for _, tt := range tests {
+		if out := tt.Fn(tt.In); out != tt.Out {
+			t.Errorf("%x: Want %x, Have %x", tt.In, tt.Out, out)
+		}
+	}
+
+	// Targets without a binding query panic, rather than restoring the
+	// wrong binding.
+	for _, fn := range []func(gl.GLenum) gl.GLenum{bufferBindingFor, textureBindingFor} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("gl.RENDERBUFFER accepted")
+				}
+			}()
+			fn(gl.RENDERBUFFER)
+		}()
+	}
+}
+
+// Nested binding contexts restore the outer binding, not zero.
+func TestBindingContexts(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		a, b := gl.GenBuffer(), gl.GenBuffer()
+		defer a.Delete()
+		defer b.Delete()
+		va, vb := gl.GenVertexArray(), gl.GenVertexArray()
+		defer va.Delete()
+		defer vb.Delete()
+
+		With(Compound(BindBuffer{gl.ARRAY_BUFFER, a}, BindVertexArray{va}), func() {
+			With(Compound(BindBuffer{gl.ARRAY_BUFFER, b}, BindVertexArray{vb}), func() {
+				if getBinding(gl.ARRAY_BUFFER_BINDING) != uint32(b) ||
+					getBinding(gl.VERTEX_ARRAY_BINDING) != uint32(vb) {
+					t.Errorf("inner bindings not made")
+				}
+			})
+			if buf := getBinding(gl.ARRAY_BUFFER_BINDING); buf != uint32(a) {
+				t.Errorf("buffer: Want %d, Have %d", a, buf)
+			}
+			if vao := getBinding(gl.VERTEX_ARRAY_BINDING); vao != uint32(va) {
+				t.Errorf("vertex array: Want %d, Have %d", va, vao)
+			}
+		})
+		if getBinding(gl.ARRAY_BUFFER_BINDING) != 0 || getBinding(gl.VERTEX_ARRAY_BINDING) != 0 {
+			t.Errorf("outer bindings not restored")
+		}
+
+		fragment := Shader{gl.FRAGMENT_SHADER, `#version 120
+void main() { gl_FragColor = vec4(1.0); }
+`}
+		vertex := Shader{gl.VERTEX_SHADER, FullscreenVertexShader}
+		p, q := NewProgram(vertex, fragment), NewProgram(vertex, fragment)
+		defer p.Delete()
+		defer q.Delete()
+
+		With(UseProgram{p}, func() {
+			With(UseProgram{q}, func() {
+				if prog := getBinding(gl.CURRENT_PROGRAM); prog != uint32(q) {
+					t.Errorf("program: Want %d, Have %d", q, prog)
+				}
+			})
+			if prog := getBinding(gl.CURRENT_PROGRAM); prog != uint32(p) {
+				t.Errorf("program: Want %d, Have %d", p, prog)
+			}
+		})
+	}, func() {})
+}
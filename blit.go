@@ -0,0 +1,141 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"image"
+	"log"
+
+	"github.com/go-gl/gl"
+)
+
+// A BlitTarget is a source or destination of Blit: a *Framebuffer, a
+// *Texture or Window.
+type BlitTarget interface {
+	// Bind to gl.READ_FRAMEBUFFER or gl.DRAW_FRAMEBUFFER.
+	BindFramebuffer(target gl.GLenum)
+}
+
+type windowTarget struct{}
+
+func (windowTarget) BindFramebuffer(target gl.GLenum) {
+	gl.Framebuffer(0).BindTarget(target)
+}
+
+// The window's default framebuffer, as a BlitTarget.
+var Window BlitTarget = windowTarget{}
+
+// BindFramebuffer binds a framebuffer with t as its only color attachment.
+// This makes *Texture a BlitTarget.
+func (t *Texture) BindFramebuffer(target gl.GLenum) {
+	(&Framebuffer{Texture: t}).BindFramebuffer(target)
+}
+
+// Classes of color formats. Blits and copies are only possible between
+// formats of the same class.
+const (
+	formatNormalized = iota // Normalized fixed point or floating point.
+	formatSigned            // Signed integer.
+	formatUnsigned          // Unsigned integer.
+)
+
+// formatClass returns the class of a color internal format.
+func formatClass(internalformat gl.GLenum) int {
+	switch internalformat {
+	case gl.R8I, gl.R16I, gl.R32I, gl.RG8I, gl.RG16I, gl.RG32I,
+		gl.RGB8I, gl.RGB16I, gl.RGB32I, gl.RGBA8I, gl.RGBA16I, gl.RGBA32I:
+		return formatSigned
+	case gl.R8UI, gl.R16UI, gl.R32UI, gl.RG8UI, gl.RG16UI, gl.RG32UI,
+		gl.RGB8UI, gl.RGB16UI, gl.RGB32UI, gl.RGBA8UI, gl.RGBA16UI,
+		gl.RGBA32UI, gl.RGB10_A2UI:
+		return formatUnsigned
+	}
+	return formatNormalized
+}
+
+// blitFormat returns the internal format of the color buffer read or written
+// by a blit to or from b.
+func blitFormat(b BlitTarget) gl.GLenum {
+	var t *Texture
+	switch b := b.(type) {
+	case *Texture:
+		t = b
+	case *Framebuffer:
+		if colors := b.colors(); len(colors) > 0 {
+			t = colors[0]
+		}
+	}
+	if t == nil {
+		return gl.RGBA8
+	}
+	return textureFormat(t, 0, 0)
+}
+
+// Blit copies srcRect of src into dstRect of dst with glBlitFramebuffer,
+// scaling if their sizes differ. Mask is a combination of
+// gl.COLOR_BUFFER_BIT, gl.DEPTH_BUFFER_BIT and gl.STENCIL_BUFFER_BIT, and
+// filter gl.NEAREST or gl.LINEAR. The framebuffer bindings are restored.
+// Example:
+//     r := image.Rect(0, 0, w, h)
+//     Blit(fb, Window, r, r, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+func Blit(src, dst BlitTarget, srcRect, dstRect image.Rectangle,
+	mask gl.GLbitfield, filter gl.GLenum) {
+
+	if mask&(gl.DEPTH_BUFFER_BIT|gl.STENCIL_BUFFER_BIT) != 0 {
+		if filter != gl.NEAREST {
+			log.Panic("Blit: depth and stencil require gl.NEAREST filtering")
+		}
+		if srcRect.Size() != dstRect.Size() {
+			log.Panicf("Blit: cannot scale depth or stencil from %v to %v",
+				srcRect.Size(), dstRect.Size())
+		}
+	}
+	if mask&gl.COLOR_BUFFER_BIT != 0 {
+		sf, df := blitFormat(src), blitFormat(dst)
+		if formatClass(sf) != formatClass(df) {
+			log.Panicf("Blit: incompatible color formats %x and %x", sf, df)
+		}
+		if filter == gl.LINEAR && formatClass(sf) != formatNormalized {
+			log.Panic("Blit: integer formats require gl.NEAREST filtering")
+		}
+	}
+
+	read := getBinding(gl.READ_FRAMEBUFFER_BINDING)
+	draw := getBinding(gl.DRAW_FRAMEBUFFER_BINDING)
+	defer func() {
+		gl.Framebuffer(read).BindTarget(gl.READ_FRAMEBUFFER)
+		gl.Framebuffer(draw).BindTarget(gl.DRAW_FRAMEBUFFER)
+	}()
+
+	src.BindFramebuffer(gl.READ_FRAMEBUFFER)
+	dst.BindFramebuffer(gl.DRAW_FRAMEBUFFER)
+	gl.BlitFramebuffer(
+		srcRect.Min.X, srcRect.Min.Y, srcRect.Max.X, srcRect.Max.Y,
+		dstRect.Min.X, dstRect.Min.Y, dstRect.Max.X, dstRect.Max.Y,
+		mask, filter)
+}
+
+// CopyFrom copies the rectangle r of the color buffer of src into the base
+// level of t at x, y, with glCopyTexSubImage2D. The read framebuffer binding
+// is restored.
+func (t *Texture) CopyFrom(src BlitTarget, r image.Rectangle, x, y int) {
+	dst := image.Rect(x, y, x+r.Dx(), y+r.Dy())
+	if !dst.In(image.Rect(0, 0, t.W, t.H)) {
+		log.Panicf("CopyFrom: %v outside %dx%d texture", dst, t.W, t.H)
+	}
+	sf, df := blitFormat(src), blitFormat(t)
+	if formatClass(sf) != formatClass(df) {
+		log.Panicf("CopyFrom: incompatible color formats %x and %x", sf, df)
+	}
+
+	read := getBinding(gl.READ_FRAMEBUFFER_BINDING)
+	defer gl.Framebuffer(read).BindTarget(gl.READ_FRAMEBUFFER)
+
+	src.BindFramebuffer(gl.READ_FRAMEBUFFER)
+	With(t, func() {
+		gl.CopyTexSubImage2D(gl.TEXTURE_2D, 0, x, y,
+			r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+	})
+}
@@ -0,0 +1,31 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl"
+)
+
+func TestFormatClass(t *testing.T) {
+	tests := []struct {
+		Format gl.GLenum
+		Class  int
+	}{
+		{gl.RGBA, formatNormalized},
+		{gl.RGBA8, formatNormalized},
+		{gl.RGBA16F, formatNormalized},
+		{gl.RGBA32I, formatSigned},
+		{gl.R8I, formatSigned},
+		{gl.RGBA8UI, formatUnsigned},
+		{gl.RGB10_A2UI, formatUnsigned},
+	}
+	for _, test := range tests {
+		if c := formatClass(test.Format); c != test.Class {
+			t.Errorf("formatClass(%x) = %d, want %d", test.Format, c, test.Class)
+		}
+	}
+}
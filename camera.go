@@ -0,0 +1,160 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"github.com/go-gl-legacy/glh/glmath"
+	"github.com/go-gl/gl"
+)
+
+// Set the GL_PROJECTION matrix to a perspective projection, like
+// gluPerspective. Fovy is the vertical field of view in degrees; the aspect
+// ratio is derived from the viewport. The matrix mode is GL_MODELVIEW inside
+// the context.
+type Perspective struct{ Fovy, Near, Far float64 }
+
+func (p Perspective) Enter() {
+	enterProjection(p.Matrix(viewportAspect()))
+}
+
+func (p Perspective) Exit() { exitProjection() }
+
+// Matrix returns the projection matrix for the given aspect ratio.
+func (p Perspective) Matrix(aspect float64) glmath.Mat4 {
+	return glmath.Perspective(p.Fovy, aspect, p.Near, p.Far)
+}
+
+// Set the GL_PROJECTION matrix to an orthographic projection with the given
+// bounds, like glOrtho. The matrix mode is GL_MODELVIEW inside the context.
+type Orthographic struct{ Left, Right, Bottom, Top, Near, Far float64 }
+
+func (o Orthographic) Enter() { enterProjection(o.Matrix()) }
+
+func (o Orthographic) Exit() { exitProjection() }
+
+// Matrix returns the projection matrix.
+func (o Orthographic) Matrix() glmath.Mat4 {
+	return glmath.Ortho(o.Left, o.Right, o.Bottom, o.Top, o.Near, o.Far)
+}
+
+// aspectRatio returns the aspect ratio of a w by h viewport, or 1 for an empty
+// one, such as that of a minimised window.
+func aspectRatio(w, h float64) float64 {
+	if w == 0 || h == 0 {
+		return 1
+	}
+	return w / h
+}
+
+// viewportAspect returns the aspect ratio of the current viewport.
+func viewportAspect() float64 { return aspectRatio(GetViewportWHD()) }
+
+// enterProjection pushes the projection matrix, replaces it with m and
+// switches to the modelview matrix mode.
+func enterProjection(m glmath.Mat4) {
+	Matrix{gl.PROJECTION}.Enter()
+	gl.LoadMatrixd((*[16]float64)(&m))
+	gl.MatrixMode(gl.MODELVIEW)
+}
+
+// exitProjection undoes enterProjection. The matrix mode may have been
+// changed inside the context, so switch back before popping.
+func exitProjection() {
+	gl.MatrixMode(gl.PROJECTION)
+	Matrix{gl.PROJECTION}.Exit()
+}
+
+// Multiply the GL_MODELVIEW matrix by a viewing transformation, like
+// gluLookAt. The matrix is restored on Exit.
+type LookAt struct{ Eye, Center, Up glmath.Vec3 }
+
+func (l LookAt) Enter() {
+	m := l.Matrix()
+	Matrix{gl.MODELVIEW}.Enter()
+	gl.MultMatrixd((*[16]float64)(&m))
+}
+
+func (l LookAt) Exit() {
+	gl.MatrixMode(gl.MODELVIEW)
+	Matrix{gl.MODELVIEW}.Exit()
+}
+
+// Matrix returns the viewing transformation.
+func (l LookAt) Matrix() glmath.Mat4 {
+	return glmath.LookAt(l.Eye, l.Center, l.Up)
+}
+
+// A Camera combines a projection and a viewing transformation. It can be
+// used as a context, which replaces the GL_PROJECTION matrix and loads the
+// view into the GL_MODELVIEW matrix, or produce matrices for shader uniforms.
+type Camera struct {
+	Eye, Center, Up glmath.Vec3
+
+	// Vertical field of view in degrees.
+	// Zero selects an orthographic projection.
+	Fovy float64
+
+	// Vertical extent of the view volume in world units,
+	// for orthographic projections.
+	Height float64
+
+	Near, Far float64
+}
+
+// NewCamera returns a perspective camera at eye, looking at center.
+func NewCamera(eye, center glmath.Vec3, fovy, near, far float64) *Camera {
+	return &Camera{
+		Eye:    eye,
+		Center: center,
+		Up:     glmath.Vec3{0, 1, 0},
+		Fovy:   fovy,
+		Near:   near,
+		Far:    far,
+	}
+}
+
+// ProjectionMatrix returns the projection matrix for the given aspect ratio.
+func (c *Camera) ProjectionMatrix(aspect float64) glmath.Mat4 {
+	if c.Fovy == 0 {
+		h := c.Height / 2
+		return Orthographic{-h * aspect, h * aspect, -h, h, c.Near, c.Far}.Matrix()
+	}
+	return Perspective{c.Fovy, c.Near, c.Far}.Matrix(aspect)
+}
+
+// ViewMatrix returns the viewing transformation.
+func (c *Camera) ViewMatrix() glmath.Mat4 {
+	return LookAt{c.Eye, c.Center, c.Up}.Matrix()
+}
+
+// Uniforms uploads the projection matrix, for the aspect ratio of the current
+// viewport, and the view matrix to the given mat4 shader uniforms.
+func (c *Camera) Uniforms(projection, view gl.UniformLocation) {
+	projection.UniformMatrix4fv(false, c.ProjectionMatrix(viewportAspect()).Float32())
+	view.UniformMatrix4fv(false, c.ViewMatrix().Float32())
+}
+
+// Ray returns the ray through window co-ordinate x, y for a viewport of
+// w by h pixels. Like WindowToProj, y is measured from the top. The ray
+// starts on the near plane.
+func (c *Camera) Ray(x, y float64, w, h int) glmath.Ray {
+	viewport := [4]int{0, 0, w, h}
+	ray, _ := glmath.PickRay(x, float64(h)-y, c.ViewMatrix(),
+		c.ProjectionMatrix(aspectRatio(float64(w), float64(h))), viewport)
+	return ray
+}
+
+func (c Camera) Enter() {
+	enterProjection(c.ProjectionMatrix(viewportAspect()))
+	view := c.ViewMatrix()
+	Matrix{gl.MODELVIEW}.Enter()
+	gl.LoadMatrixd((*[16]float64)(&view))
+}
+
+func (c Camera) Exit() {
+	gl.MatrixMode(gl.MODELVIEW)
+	Matrix{gl.MODELVIEW}.Exit()
+	exitProjection()
+}
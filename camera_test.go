@@ -0,0 +1,147 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"testing"
+
+	"github.com/go-gl-legacy/glh/glmath"
+)
+
+// clip transforms p by m and divides by w.
+func clip(m glmath.Mat4, p glmath.Vec3) glmath.Vec3 {
+	return m.MulVec(p.Vec4(1)).Vec3()
+}
+
+func TestPerspectiveMatrix(t *testing.T) {
+	p := Perspective{90, 1, 10}
+	m := p.Matrix(2)
+
+	tests := []struct {
+		In, Out glmath.Vec3
+	}{
+		// Near and far plane map to -1 and 1.
+		{glmath.Vec3{0, 0, -1}, glmath.Vec3{0, 0, -1}},
+		{glmath.Vec3{0, 0, -10}, glmath.Vec3{0, 0, 1}},
+		// A 90 degree field of view reaches y = distance at the top edge,
+		// and the aspect ratio widens it horizontally.
+		{glmath.Vec3{0, 1, -1}, glmath.Vec3{0, 1, -1}},
+		{glmath.Vec3{20, -10, -10}, glmath.Vec3{1, -1, 1}},
+	}
+
+	for _, test := range tests {
+		if out := clip(m, test.In); !nearVec3(out, test.Out) {
+			t.Errorf("%v: Want %v, Have %v", test.In, test.Out, out)
+		}
+	}
+}
+
+func TestOrthographicMatrix(t *testing.T) {
+	o := Orthographic{0, 4, 0, 2, -1, 1}
+	m := o.Matrix()
+
+	tests := []struct {
+		In, Out glmath.Vec3
+	}{
+		{glmath.Vec3{0, 0, 1}, glmath.Vec3{-1, -1, -1}},
+		{glmath.Vec3{4, 2, -1}, glmath.Vec3{1, 1, 1}},
+		{glmath.Vec3{2, 1, 0}, glmath.Vec3{0, 0, 0}},
+	}
+
+	for _, test := range tests {
+		if out := clip(m, test.In); !nearVec3(out, test.Out) {
+			t.Errorf("%v: Want %v, Have %v", test.In, test.Out, out)
+		}
+	}
+}
+
+func TestLookAtMatrix(t *testing.T) {
+	l := LookAt{glmath.Vec3{1, 2, 3}, glmath.Vec3{1, 2, 0}, glmath.Vec3{0, 1, 0}}
+	m := l.Matrix()
+
+	tests := []struct {
+		In, Out glmath.Vec3
+	}{
+		// The eye is at the origin, looking down -Z.
+		{l.Eye, glmath.Vec3{}},
+		{l.Center, glmath.Vec3{0, 0, -3}},
+		{glmath.Vec3{2, 3, 3}, glmath.Vec3{1, 1, 0}},
+	}
+
+	for _, test := range tests {
+		if out := m.Transform(test.In); !nearVec3(out, test.Out) {
+			t.Errorf("%v: Want %v, Have %v", test.In, test.Out, out)
+		}
+	}
+}
+
+func TestAspectRatio(t *testing.T) {
+	tests := []struct {
+		W, H, Out float64
+	}{
+		{640, 480, 4.0 / 3},
+		{480, 640, 0.75},
+		// An empty viewport, such as that of a minimised window, falls back
+		// to a square aspect ratio rather than Inf or NaN.
+		{640, 0, 1},
+		{0, 480, 1},
+		{0, 0, 1},
+	}
+
+	for _, test := range tests {
+		if out := aspectRatio(test.W, test.H); out != test.Out {
+			t.Errorf("%vx%v: Want %v, Have %v", test.W, test.H, test.Out, out)
+		}
+	}
+}
+
+func TestCameraMatrices(t *testing.T) {
+	cam := NewCamera(glmath.Vec3{0, 0, 5}, glmath.Vec3{}, 60, 0.5, 50)
+
+	if cam.ProjectionMatrix(1.5) != (Perspective{60, 0.5, 50}).Matrix(1.5) {
+		t.Errorf("perspective camera does not match Perspective")
+	}
+	if cam.ViewMatrix() != (LookAt{cam.Eye, cam.Center, cam.Up}).Matrix() {
+		t.Errorf("view does not match LookAt")
+	}
+
+	// The orthographic view volume is Height tall, centered on the view
+	// axis, and as wide as the aspect ratio requires.
+	cam.Fovy, cam.Height = 0, 4
+	want := Orthographic{-3, 3, -2, 2, 0.5, 50}.Matrix()
+	if have := cam.ProjectionMatrix(1.5); have != want {
+		t.Errorf("orthographic: Want %v, Have %v", want, have)
+	}
+}
+
+func TestCameraRay(t *testing.T) {
+	cam := NewCamera(glmath.Vec3{0, 0, 5}, glmath.Vec3{}, 90, 1, 100)
+
+	// The center of the window looks straight at Center.
+	ray := cam.Ray(50, 50, 100, 100)
+	if !nearVec3(ray.Dir, glmath.Vec3{0, 0, -1}) || !nearVec3(ray.Origin, glmath.Vec3{0, 0, 4}) {
+		t.Errorf("center: Have %+v", ray)
+	}
+
+	// The top edge is 45 degrees up; window y is measured from the top.
+	ray = cam.Ray(50, 0, 100, 100)
+	if want := (glmath.Vec3{0, 1, -1}).Normalize(); !nearVec3(ray.Dir, want) {
+		t.Errorf("top: Want %v, Have %v", want, ray.Dir)
+	}
+
+	// Orthographic rays are parallel, offset by the pixel position.
+	cam.Fovy, cam.Height = 0, 10
+	ray = cam.Ray(200, 0, 200, 100)
+	if !nearVec3(ray.Dir, glmath.Vec3{0, 0, -1}) || !nearFloat(ray.Origin[0], 10) ||
+		!nearFloat(ray.Origin[1], 5) {
+		t.Errorf("orthographic corner: Have %+v", ray)
+	}
+
+	// An empty viewport still gives a finite ray.
+	cam.Fovy = 90
+	if ray = cam.Ray(0, 0, 100, 0); isNaN3(ray.Dir) || isNaN3(ray.Origin) {
+		t.Errorf("empty viewport: Have %+v", ray)
+	}
+}
@@ -21,6 +21,9 @@ type Context interface {
 //     // Changes to the matrix are undone here
 func With(c Context, action func()) {
 	defer OpenGLSentinel()()
+	if TraceContexts {
+		defer traceContext(c)()
+	}
 	c.Enter()
 	defer c.Exit()
 	action()
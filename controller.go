@@ -0,0 +1,416 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"math"
+	"time"
+
+	"github.com/go-gl-legacy/glh/glmath"
+)
+
+// Mouse buttons, as understood by camera controllers.
+type MouseButton int
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+)
+
+// Movement keys, as understood by camera controllers. Map your windowing
+// library's key codes onto these.
+type Key int
+
+const (
+	KeyForward Key = iota
+	KeyBackward
+	KeyLeft
+	KeyRight
+	KeyUp
+	KeyDown
+	numKeys
+)
+
+// A CameraController updates a Camera from abstract input events. Window
+// co-ordinates have their origin in the top left, like WindowToProj.
+//
+// Controllers implement Context by applying their camera. Entering the
+// context also records the viewport size, which the controller needs to
+// interpret window co-ordinates; call Resize before any input arrives.
+type CameraController interface {
+	Context
+
+	// Drag reports a mouse movement by dx, dy pixels with button held.
+	Drag(button MouseButton, dx, dy float64)
+
+	// Scroll reports a scroll wheel movement by delta with the cursor at x, y.
+	Scroll(x, y, delta float64)
+
+	// SetKey reports a key being pressed or released.
+	SetKey(key Key, down bool)
+
+	// Update advances the controller by dt, moving the camera towards its
+	// target state.
+	Update(dt time.Duration)
+
+	// Resize sets the viewport size.
+	Resize(w, h int)
+}
+
+// damp moves current towards target. Damping is the time constant in
+// seconds; after that time 63% of the distance is covered.
+func damp(current, target, damping float64, dt time.Duration) float64 {
+	if damping <= 0 {
+		return target
+	}
+	return target + (current-target)*math.Exp(-dt.Seconds()/damping)
+}
+
+func damp3(current, target glmath.Vec3, damping float64, dt time.Duration) glmath.Vec3 {
+	for i := range current {
+		current[i] = damp(current[i], target[i], damping, dt)
+	}
+	return current
+}
+
+// viewSize holds the viewport size for a controller.
+type viewSize struct{ w, h int }
+
+func (v *viewSize) Resize(w, h int) { v.w, v.h = w, h }
+
+// cursorRay returns the ray through window co-ordinate x, y for cam. It
+// returns false before Resize, when window co-ordinates have no meaning.
+func (v *viewSize) cursorRay(cam *Camera, x, y float64) (glmath.Ray, bool) {
+	if v.w == 0 || v.h == 0 {
+		return glmath.Ray{}, false
+	}
+	return cam.Ray(x, y, v.w, v.h), true
+}
+
+// unitsPerPixel returns the size of a pixel in world units at the given
+// distance from cam.
+func (v *viewSize) unitsPerPixel(cam *Camera, distance float64) float64 {
+	if v.h == 0 {
+		return 0
+	}
+	if cam.Fovy == 0 {
+		return cam.Height / float64(v.h)
+	}
+	return 2 * distance * math.Tan(cam.Fovy*math.Pi/360) / float64(v.h)
+}
+
+// An OrbitController rotates the camera around its center with the left
+// mouse button, pans with the middle or right button and zooms towards the
+// cursor with the scroll wheel.
+type OrbitController struct {
+	Camera *Camera
+
+	RotateSpeed float64 // Radians per pixel.
+	ZoomFactor  float64 // Distance scale per scroll unit.
+	MinDistance float64
+	MaxDistance float64
+	Damping     float64 // Time constant in seconds, zero for none.
+
+	viewSize
+	current, target orbitState
+}
+
+type orbitState struct {
+	center     glmath.Vec3
+	yaw, pitch float64
+	distance   float64
+}
+
+// NewOrbitController creates a controller orbiting cam's center, starting
+// from cam's current position.
+func NewOrbitController(cam *Camera) *OrbitController {
+	offset := cam.Eye.Sub(cam.Center)
+	distance := offset.Len()
+
+	s := orbitState{center: cam.Center, distance: distance}
+	if distance > 0 {
+		s.yaw = math.Atan2(offset[0], offset[2])
+		s.pitch = math.Asin(offset[1] / distance)
+	}
+
+	return &OrbitController{
+		Camera:      cam,
+		RotateSpeed: 0.01,
+		ZoomFactor:  1.1,
+		MinDistance: 0,
+		MaxDistance: math.Inf(1),
+		current:     s,
+		target:      s,
+	}
+}
+
+func (o *OrbitController) Drag(button MouseButton, dx, dy float64) {
+	switch button {
+	case MouseLeft:
+		o.target.yaw -= dx * o.RotateSpeed
+		o.target.pitch += dy * o.RotateSpeed
+		limit := math.Pi/2 - 1e-3
+		o.target.pitch = math.Max(-limit, math.Min(limit, o.target.pitch))
+
+	case MouseMiddle, MouseRight:
+		// The scene follows the cursor.
+		scale := o.unitsPerPixel(o.Camera, o.target.distance)
+		view := o.Camera.ViewMatrix()
+		right := glmath.Vec3{view[0], view[4], view[8]}
+		up := glmath.Vec3{view[1], view[5], view[9]}
+		move := right.Mul(-dx).Add(up.Mul(dy)).Mul(scale)
+		o.target.center = o.target.center.Add(move)
+	}
+}
+
+// Scroll zooms towards the point under the cursor on the plane through the
+// center, facing the camera. Positive delta zooms in. Before Resize it zooms
+// towards the center.
+func (o *OrbitController) Scroll(x, y, delta float64) {
+	k := math.Pow(o.ZoomFactor, -delta)
+	distance := math.Max(o.MinDistance,
+		math.Min(o.MaxDistance, o.target.distance*k))
+	if o.target.distance == 0 {
+		return
+	}
+	k = distance / o.target.distance
+
+	// Scaling both eye and center about the point under the cursor keeps
+	// that point fixed on screen.
+	cam := o.targetCamera()
+	if ray, ok := o.cursorRay(&cam, x, y); ok {
+		plane := glmath.PlaneFromNormal(cam.Eye.Sub(cam.Center), cam.Center)
+		if t, ok := ray.IntersectPlane(plane); ok {
+			p := ray.At(t)
+			o.target.center = p.Add(o.target.center.Sub(p).Mul(k))
+		}
+	}
+	o.target.distance = distance
+}
+
+func (o *OrbitController) SetKey(key Key, down bool) {}
+
+func (o *OrbitController) Update(dt time.Duration) {
+	o.current.center = damp3(o.current.center, o.target.center, o.Damping, dt)
+	o.current.yaw = damp(o.current.yaw, o.target.yaw, o.Damping, dt)
+	o.current.pitch = damp(o.current.pitch, o.target.pitch, o.Damping, dt)
+	o.current.distance = damp(o.current.distance, o.target.distance, o.Damping, dt)
+	o.current.apply(o.Camera)
+}
+
+// targetCamera returns the camera as it will be once damping has settled.
+func (o *OrbitController) targetCamera() Camera {
+	cam := *o.Camera
+	o.target.apply(&cam)
+	return cam
+}
+
+func (s *orbitState) apply(cam *Camera) {
+	cp := math.Cos(s.pitch)
+	cam.Center = s.center
+	cam.Eye = s.center.Add(glmath.Vec3{
+		s.distance * cp * math.Sin(s.yaw),
+		s.distance * math.Sin(s.pitch),
+		s.distance * cp * math.Cos(s.yaw),
+	})
+	cam.Up = glmath.Vec3{0, 1, 0}
+}
+
+func (o *OrbitController) Enter() {
+	o.Resize(GetViewportWH())
+	o.Camera.Enter()
+}
+
+func (o *OrbitController) Exit() { o.Camera.Exit() }
+
+// A FlyController moves the camera with the movement keys and turns it by
+// dragging with any mouse button. Scrolling moves along the ray through the
+// cursor.
+type FlyController struct {
+	Camera *Camera
+
+	Speed      float64 // World units per second.
+	LookSpeed  float64 // Radians per pixel.
+	ScrollStep float64 // World units per scroll unit.
+	Damping    float64 // Time constant in seconds, zero for none.
+
+	viewSize
+	keys            [numKeys]bool
+	current, target flyState
+}
+
+type flyState struct {
+	eye        glmath.Vec3
+	yaw, pitch float64
+}
+
+// NewFlyController creates a controller starting from cam's current position
+// and direction.
+func NewFlyController(cam *Camera) *FlyController {
+	d := cam.Center.Sub(cam.Eye).Normalize()
+	s := flyState{
+		eye:   cam.Eye,
+		yaw:   math.Atan2(-d[0], -d[2]),
+		pitch: math.Asin(d[1]),
+	}
+	return &FlyController{
+		Camera:     cam,
+		Speed:      1,
+		LookSpeed:  0.005,
+		ScrollStep: 1,
+		current:    s,
+		target:     s,
+	}
+}
+
+func (f *FlyController) Drag(button MouseButton, dx, dy float64) {
+	f.target.yaw -= dx * f.LookSpeed
+	f.target.pitch -= dy * f.LookSpeed
+	limit := math.Pi/2 - 1e-3
+	f.target.pitch = math.Max(-limit, math.Min(limit, f.target.pitch))
+}
+
+// Scroll moves along the ray through the cursor, or straight ahead before
+// Resize.
+func (f *FlyController) Scroll(x, y, delta float64) {
+	cam := *f.Camera
+	f.target.apply(&cam)
+	dir := f.target.forward()
+	if ray, ok := f.cursorRay(&cam, x, y); ok {
+		dir = ray.Dir
+	}
+	f.target.eye = f.target.eye.Add(dir.Mul(delta * f.ScrollStep))
+}
+
+func (f *FlyController) SetKey(key Key, down bool) {
+	if key >= 0 && key < numKeys {
+		f.keys[key] = down
+	}
+}
+
+func (f *FlyController) Update(dt time.Duration) {
+	axis := func(pos, neg Key) float64 {
+		var v float64
+		if f.keys[pos] {
+			v++
+		}
+		if f.keys[neg] {
+			v--
+		}
+		return v
+	}
+
+	forward := f.target.forward()
+	right := forward.Cross(glmath.Vec3{0, 1, 0}).Normalize()
+	fwd, side, up := axis(KeyForward, KeyBackward), axis(KeyRight, KeyLeft),
+		axis(KeyUp, KeyDown)
+
+	move := forward.Mul(fwd).Add(right.Mul(side)).Add(glmath.Vec3{0, up, 0})
+	f.target.eye = f.target.eye.Add(move.Mul(f.Speed * dt.Seconds()))
+
+	f.current.eye = damp3(f.current.eye, f.target.eye, f.Damping, dt)
+	f.current.yaw = damp(f.current.yaw, f.target.yaw, f.Damping, dt)
+	f.current.pitch = damp(f.current.pitch, f.target.pitch, f.Damping, dt)
+	f.current.apply(f.Camera)
+}
+
+func (s *flyState) forward() glmath.Vec3 {
+	cp := math.Cos(s.pitch)
+	return glmath.Vec3{-math.Sin(s.yaw) * cp, math.Sin(s.pitch), -math.Cos(s.yaw) * cp}
+}
+
+func (s *flyState) apply(cam *Camera) {
+	cam.Eye = s.eye
+	cam.Center = s.eye.Add(s.forward())
+	cam.Up = glmath.Vec3{0, 1, 0}
+}
+
+func (f *FlyController) Enter() {
+	f.Resize(GetViewportWH())
+	f.Camera.Enter()
+}
+
+func (f *FlyController) Exit() { f.Camera.Exit() }
+
+// A PanZoomController drives an orthographic camera looking down the Z axis,
+// for 2D views. Dragging with any button pans, scrolling zooms towards the
+// cursor.
+type PanZoomController struct {
+	Camera *Camera
+
+	ZoomFactor float64 // Height scale per scroll unit.
+	MinHeight  float64
+	MaxHeight  float64
+	Damping    float64 // Time constant in seconds, zero for none.
+
+	viewSize
+	current, target panZoomState
+}
+
+type panZoomState struct {
+	x, y   float64
+	height float64
+}
+
+// NewPanZoomController creates a controller for the orthographic camera cam.
+func NewPanZoomController(cam *Camera) *PanZoomController {
+	if cam.Fovy != 0 {
+		panic("PanZoomController requires an orthographic camera")
+	}
+	s := panZoomState{cam.Center[0], cam.Center[1], cam.Height}
+	return &PanZoomController{
+		Camera:     cam,
+		ZoomFactor: 1.1,
+		MaxHeight:  math.Inf(1),
+		current:    s,
+		target:     s,
+	}
+}
+
+func (p *PanZoomController) Drag(button MouseButton, dx, dy float64) {
+	if p.h == 0 {
+		return
+	}
+	scale := p.target.height / float64(p.h)
+	p.target.x -= dx * scale
+	p.target.y += dy * scale
+}
+
+// Scroll zooms keeping the point under the cursor fixed. Positive delta
+// zooms in.
+func (p *PanZoomController) Scroll(x, y, delta float64) {
+	if p.h == 0 {
+		return
+	}
+	height := p.target.height * math.Pow(p.ZoomFactor, -delta)
+	height = math.Max(p.MinHeight, math.Min(p.MaxHeight, height))
+
+	ox, oy := x-float64(p.w)/2, float64(p.h)/2-y
+	before, after := p.target.height/float64(p.h), height/float64(p.h)
+	p.target.x += ox * (before - after)
+	p.target.y += oy * (before - after)
+	p.target.height = height
+}
+
+func (p *PanZoomController) SetKey(key Key, down bool) {}
+
+func (p *PanZoomController) Update(dt time.Duration) {
+	p.current.x = damp(p.current.x, p.target.x, p.Damping, dt)
+	p.current.y = damp(p.current.y, p.target.y, p.Damping, dt)
+	p.current.height = damp(p.current.height, p.target.height, p.Damping, dt)
+
+	cam := p.Camera
+	cam.Eye[0], cam.Eye[1] = p.current.x, p.current.y
+	cam.Center[0], cam.Center[1] = p.current.x, p.current.y
+	cam.Height = p.current.height
+}
+
+func (p *PanZoomController) Enter() {
+	p.Resize(GetViewportWH())
+	p.Camera.Enter()
+}
+
+func (p *PanZoomController) Exit() { p.Camera.Exit() }
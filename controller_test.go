@@ -0,0 +1,312 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-gl-legacy/glh/glmath"
+)
+
+const controllerEpsilon = 1e-6
+
+func nearFloat(a, b float64) bool { return math.Abs(a-b) < controllerEpsilon }
+
+func nearVec3(a, b glmath.Vec3) bool {
+	return nearFloat(a[0], b[0]) && nearFloat(a[1], b[1]) && nearFloat(a[2], b[2])
+}
+
+func isNaN3(v glmath.Vec3) bool {
+	return math.IsNaN(v[0]) || math.IsNaN(v[1]) || math.IsNaN(v[2])
+}
+
+// screenPoint returns the window co-ordinates of world point p as seen by cam
+// in a w by h viewport, with y measured from the top.
+func screenPoint(cam *Camera, p glmath.Vec3, w, h int) (x, y float64) {
+	m := cam.ProjectionMatrix(float64(w) / float64(h)).Mul(cam.ViewMatrix())
+	c := m.MulVec(p.Vec4(1))
+	x = (c[0]/c[3] + 1) / 2 * float64(w)
+	y = (1 - c[1]/c[3]) / 2 * float64(h)
+	return x, y
+}
+
+func TestDamp(t *testing.T) {
+	tests := []struct {
+		Current, Target, Damping float64
+		Dt                       time.Duration
+		Out                      float64
+	}{
+		{0, 1, 0, 0, 1},
+		{0, 1, -1, time.Second, 1},
+		{0, 1, 1, 0, 0},
+		{0, 1, 1, time.Second, 1 - math.Exp(-1)},
+		{2, 0, 0.5, time.Second, 2 * math.Exp(-2)},
+	}
+
+	for i, test := range tests {
+		out := damp(test.Current, test.Target, test.Damping, test.Dt)
+		if !nearFloat(out, test.Out) {
+			t.Errorf("%d: Want %v, Have %v", i, test.Out, out)
+		}
+	}
+}
+
+func TestOrbitControllerApply(t *testing.T) {
+	eyes := []glmath.Vec3{
+		{0, 0, 5},
+		{3, 0, 0},
+		{1, 2, -3},
+		{-4, -1, 2},
+	}
+	center := glmath.Vec3{1, 1, 1}
+
+	for _, eye := range eyes {
+		eye = eye.Add(center)
+		cam := NewCamera(eye, center, 45, 0.1, 100)
+		o := NewOrbitController(cam)
+		o.Update(0)
+		if !nearVec3(cam.Eye, eye) || !nearVec3(cam.Center, center) {
+			t.Errorf("eye %v: Have eye %v, center %v", eye, cam.Eye, cam.Center)
+		}
+	}
+}
+
+func TestOrbitControllerDrag(t *testing.T) {
+	cam := NewCamera(glmath.Vec3{0, 0, 5}, glmath.Vec3{}, 45, 0.1, 100)
+	o := NewOrbitController(cam)
+	o.Resize(640, 480)
+
+	// A quarter turn to the left keeps the distance.
+	o.Drag(MouseLeft, -math.Pi/2/o.RotateSpeed, 0)
+	o.Update(0)
+	if want := (glmath.Vec3{5, 0, 0}); !nearVec3(cam.Eye, want) {
+		t.Errorf("rotate: Want %v, Have %v", want, cam.Eye)
+	}
+
+	// Pitch stops short of the pole, where the up vector would be parallel
+	// to the view direction.
+	o.Drag(MouseLeft, 0, 1e6)
+	o.Update(0)
+	if o.target.pitch >= math.Pi/2 || o.target.pitch < math.Pi/2-1e-2 {
+		t.Errorf("pitch not clamped: %v", o.target.pitch)
+	}
+	if !nearFloat(cam.Eye.Sub(cam.Center).Len(), 5) {
+		t.Errorf("pitch changed the distance: %v", cam.Eye.Sub(cam.Center).Len())
+	}
+	o.Drag(MouseLeft, 0, -2e6)
+	if o.target.pitch <= -math.Pi/2 {
+		t.Errorf("pitch not clamped: %v", o.target.pitch)
+	}
+
+	// Panning moves eye and center together, by one pixel's worth of world
+	// units at the center per pixel dragged.
+	cam = NewCamera(glmath.Vec3{0, 0, 5}, glmath.Vec3{}, 90, 0.1, 100)
+	o = NewOrbitController(cam)
+	o.Resize(100, 100)
+	o.Drag(MouseRight, 10, 0)
+	o.Update(0)
+	if want := (glmath.Vec3{-1, 0, 0}); !nearVec3(cam.Center, want) {
+		t.Errorf("pan: Want center %v, Have %v", want, cam.Center)
+	}
+	if want := (glmath.Vec3{-1, 0, 5}); !nearVec3(cam.Eye, want) {
+		t.Errorf("pan: Want eye %v, Have %v", want, cam.Eye)
+	}
+}
+
+func TestOrbitControllerScroll(t *testing.T) {
+	cam := NewCamera(glmath.Vec3{0, 0, 10}, glmath.Vec3{}, 60, 0.1, 100)
+	o := NewOrbitController(cam)
+	o.MinDistance, o.MaxDistance = 2, 20
+	o.Resize(640, 480)
+
+	// The point under the cursor on the center plane stays put.
+	x, y := 500.0, 100.0
+	ray, _ := o.cursorRay(cam, x, y)
+	plane := glmath.PlaneFromNormal(glmath.Vec3{0, 0, 1}, glmath.Vec3{})
+	d, _ := ray.IntersectPlane(plane)
+	p := ray.At(d)
+
+	o.Scroll(x, y, 3)
+	o.Update(0)
+	if want := 10 * math.Pow(o.ZoomFactor, -3); !nearFloat(o.target.distance, want) {
+		t.Errorf("distance: Want %v, Have %v", want, o.target.distance)
+	}
+	if sx, sy := screenPoint(cam, p, 640, 480); !nearFloat(sx, x) || !nearFloat(sy, y) {
+		t.Errorf("cursor point moved: Want %v, %v, Have %v, %v", x, y, sx, sy)
+	}
+
+	// Zooming stops at the limits.
+	o.Scroll(x, y, 100)
+	if o.target.distance != o.MinDistance {
+		t.Errorf("min: Want %v, Have %v", o.MinDistance, o.target.distance)
+	}
+	o.Scroll(x, y, -100)
+	if o.target.distance != o.MaxDistance {
+		t.Errorf("max: Want %v, Have %v", o.MaxDistance, o.target.distance)
+	}
+}
+
+func TestOrbitControllerScrollBeforeResize(t *testing.T) {
+	cam := NewCamera(glmath.Vec3{0, 0, 10}, glmath.Vec3{}, 60, 0.1, 100)
+	o := NewOrbitController(cam)
+
+	o.Scroll(100, 100, 1)
+	o.Update(0)
+	if isNaN3(cam.Eye) || isNaN3(cam.Center) {
+		t.Fatalf("NaN camera: eye %v, center %v", cam.Eye, cam.Center)
+	}
+	if !nearVec3(cam.Center, glmath.Vec3{}) {
+		t.Errorf("center moved: %v", cam.Center)
+	}
+	if want := 10 / o.ZoomFactor; !nearFloat(cam.Eye.Len(), want) {
+		t.Errorf("distance: Want %v, Have %v", want, cam.Eye.Len())
+	}
+}
+
+func TestFlyController(t *testing.T) {
+	cam := NewCamera(glmath.Vec3{0, 0, 5}, glmath.Vec3{}, 60, 0.1, 100)
+	f := NewFlyController(cam)
+	f.Speed = 2
+
+	// The initial direction survives the round trip through yaw and pitch.
+	f.Update(0)
+	if want := (glmath.Vec3{0, 0, 4}); !nearVec3(cam.Center, want) {
+		t.Errorf("center: Want %v, Have %v", want, cam.Center)
+	}
+
+	tests := []struct {
+		Key Key
+		Out glmath.Vec3
+	}{
+		{KeyForward, glmath.Vec3{0, 0, -1}},
+		{KeyBackward, glmath.Vec3{0, 0, 1}},
+		{KeyLeft, glmath.Vec3{-1, 0, 0}},
+		{KeyRight, glmath.Vec3{1, 0, 0}},
+		{KeyUp, glmath.Vec3{0, 1, 0}},
+		{KeyDown, glmath.Vec3{0, -1, 0}},
+	}
+
+	for _, test := range tests {
+		before := cam.Eye
+		f.SetKey(test.Key, true)
+		f.Update(time.Second / 2)
+		f.SetKey(test.Key, false)
+		if have := cam.Eye.Sub(before); !nearVec3(have, test.Out) {
+			t.Errorf("key %d: Want %v, Have %v", test.Key, test.Out, have)
+		}
+	}
+
+	// Opposite keys cancel out; keys out of range are ignored.
+	before := cam.Eye
+	f.SetKey(KeyForward, true)
+	f.SetKey(KeyBackward, true)
+	f.SetKey(numKeys, true)
+	f.SetKey(-1, true)
+	f.Update(time.Second)
+	if !nearVec3(cam.Eye, before) {
+		t.Errorf("cancelled keys moved the eye: %v", cam.Eye.Sub(before))
+	}
+}
+
+func TestFlyControllerDragAndScroll(t *testing.T) {
+	cam := NewCamera(glmath.Vec3{}, glmath.Vec3{0, 0, -1}, 60, 0.1, 100)
+	f := NewFlyController(cam)
+
+	// Turning a quarter to the left looks down -X.
+	f.Drag(MouseLeft, -math.Pi/2/f.LookSpeed, 0)
+	f.Update(0)
+	if want := (glmath.Vec3{-1, 0, 0}); !nearVec3(cam.Center, want) {
+		t.Errorf("turn: Want %v, Have %v", want, cam.Center)
+	}
+
+	f.Drag(MouseLeft, 0, -1e6)
+	if f.target.pitch >= math.Pi/2 {
+		t.Errorf("pitch not clamped: %v", f.target.pitch)
+	}
+	f.Drag(MouseLeft, 0, 1e6)
+
+	// Before Resize scrolling moves straight ahead.
+	f = NewFlyController(NewCamera(glmath.Vec3{}, glmath.Vec3{0, 0, -1}, 60, 0.1, 100))
+	f.Scroll(10, 10, 2)
+	if want := (glmath.Vec3{0, 0, -2}); !nearVec3(f.target.eye, want) {
+		t.Errorf("scroll before Resize: Want %v, Have %v", want, f.target.eye)
+	}
+
+	// After Resize it follows the cursor; the view center is straight ahead.
+	f = NewFlyController(NewCamera(glmath.Vec3{}, glmath.Vec3{0, 0, -1}, 60, 0.1, 100))
+	f.Resize(640, 480)
+	f.Scroll(320, 240, 2)
+	if want := (glmath.Vec3{0, 0, -2}); !nearVec3(f.target.eye, want) {
+		t.Errorf("scroll at center: Want %v, Have %v", want, f.target.eye)
+	}
+	f.Scroll(640, 240, 1)
+	if d := f.target.eye.Sub(glmath.Vec3{0, 0, -2}); !nearFloat(d.Len(), 1) || d[0] <= 0 {
+		t.Errorf("scroll at right edge: Have %v", d)
+	}
+}
+
+func TestPanZoomController(t *testing.T) {
+	cam := &Camera{
+		Eye:    glmath.Vec3{0, 0, 1},
+		Up:     glmath.Vec3{0, 1, 0},
+		Height: 10,
+		Near:   -1,
+		Far:    1,
+	}
+	p := NewPanZoomController(cam)
+	p.MinHeight, p.MaxHeight = 1, 100
+
+	// Before Resize input is ignored.
+	p.Drag(MouseLeft, 10, 10)
+	p.Scroll(10, 10, 1)
+	if p.target != p.current {
+		t.Errorf("input before Resize changed the target: %+v", p.target)
+	}
+
+	p.Resize(200, 100)
+
+	// The scene follows the cursor: ten pixels are one unit.
+	p.Drag(MouseLeft, 20, 10)
+	p.Update(0)
+	if cam.Center[0] != -2 || cam.Center[1] != 1 || cam.Eye[0] != -2 || cam.Eye[1] != 1 {
+		t.Errorf("drag: Have eye %v, center %v", cam.Eye, cam.Center)
+	}
+
+	// The point under the cursor stays put.
+	x, y := 150.0, 20.0
+	world := func() (float64, float64) {
+		scale := p.current.height / 100
+		return p.current.x + (x-100)*scale, p.current.y + (50-y)*scale
+	}
+	wx, wy := world()
+	p.Scroll(x, y, 5)
+	p.Update(0)
+	if want := 10 * math.Pow(p.ZoomFactor, -5); !nearFloat(cam.Height, want) {
+		t.Errorf("height: Want %v, Have %v", want, cam.Height)
+	}
+	if hx, hy := world(); !nearFloat(hx, wx) || !nearFloat(hy, wy) {
+		t.Errorf("cursor point moved: Want %v, %v, Have %v, %v", wx, wy, hx, hy)
+	}
+
+	p.Scroll(x, y, 100)
+	if p.target.height != p.MinHeight {
+		t.Errorf("min: Want %v, Have %v", p.MinHeight, p.target.height)
+	}
+	p.Scroll(x, y, -100)
+	if p.target.height != p.MaxHeight {
+		t.Errorf("max: Want %v, Have %v", p.MaxHeight, p.target.height)
+	}
+}
+
+func TestPanZoomControllerPerspective(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewPanZoomController accepted a perspective camera")
+		}
+	}()
+	NewPanZoomController(NewCamera(glmath.Vec3{0, 0, 1}, glmath.Vec3{}, 45, 0.1, 10))
+}
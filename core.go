@@ -0,0 +1,428 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"log"
+
+	"github.com/go-gl-legacy/glh/glmath"
+	"github.com/go-gl/gl"
+)
+
+// The contexts in this file are replacements for Matrix, Attrib, Enable,
+// Disable, WindowCoords and Primitive which do not rely on glPushAttrib,
+// glPushMatrix or glBegin. These calls do not exist in a 3.2+ core profile.
+// Instead, state is queried on Enter and restored on Exit.
+
+// Saved state for contexts which restore values they queried on Enter.
+// Contexts are always exited in the reverse order of entering them, so a
+// single stack is sufficient.
+var savedState []interface{}
+
+// pushState saves a value to be restored by the matching popState.
+func pushState(v interface{}) {
+	savedState = append(savedState, v)
+}
+
+// popState returns the most recently saved value.
+func popState() interface{} {
+	n := len(savedState) - 1
+	if n < 0 {
+		log.Panic("glh: saved state stack underflow")
+	}
+	v := savedState[n]
+	savedState[n] = nil
+	savedState = savedState[:n]
+	return v
+}
+
+// MatrixStack is a pure-Go matrix stack, replacing the fixed-function
+// projection and modelview stacks. Matrices are stored column-major, like
+// OpenGL expects them.
+type MatrixStack struct {
+	stack []glmath.Mat4
+}
+
+// The matrix stacks used by CoreMatrix and CoreWindowCoords. Upload them to
+// your shader with MatrixStack.Uniform.
+var (
+	Projection = NewMatrixStack()
+	ModelView  = NewMatrixStack()
+)
+
+// NewMatrixStack returns a stack holding a single identity matrix.
+func NewMatrixStack() *MatrixStack {
+	return &MatrixStack{[]glmath.Mat4{glmath.Ident4()}}
+}
+
+// MatrixStackFor returns the core matrix stack for gl.PROJECTION or
+// gl.MODELVIEW.
+func MatrixStackFor(mode gl.GLenum) *MatrixStack {
+	switch mode {
+	case gl.PROJECTION:
+		return Projection
+	case gl.MODELVIEW:
+		return ModelView
+	}
+	log.Panicf("glh: no core matrix stack for mode %x", mode)
+	return nil
+}
+
+// Depth returns the number of matrices on the stack.
+func (s *MatrixStack) Depth() int { return len(s.stack) }
+
+// Top returns the current matrix.
+func (s *MatrixStack) Top() glmath.Mat4 { return s.stack[len(s.stack)-1] }
+
+// Push duplicates the current matrix.
+func (s *MatrixStack) Push() {
+	s.stack = append(s.stack, s.Top())
+}
+
+// Pop discards the current matrix.
+func (s *MatrixStack) Pop() {
+	if len(s.stack) == 1 {
+		log.Panic("glh: matrix stack underflow")
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+}
+
+// Load replaces the current matrix.
+func (s *MatrixStack) Load(m glmath.Mat4) { s.stack[len(s.stack)-1] = m }
+
+// LoadIdentity replaces the current matrix with the identity matrix.
+func (s *MatrixStack) LoadIdentity() { s.Load(glmath.Ident4()) }
+
+// Mult post-multiplies the current matrix by m, like glMultMatrix.
+func (s *MatrixStack) Mult(m glmath.Mat4) { s.Load(s.Top().Mul(m)) }
+
+// Ortho multiplies the current matrix by an orthographic projection,
+// like glOrtho.
+func (s *MatrixStack) Ortho(left, right, bottom, top, near, far float64) {
+	s.Mult(glmath.Ortho(left, right, bottom, top, near, far))
+}
+
+// Translate multiplies the current matrix by a translation, like glTranslate.
+func (s *MatrixStack) Translate(x, y, z float64) {
+	s.Mult(glmath.Translate(glmath.Vec3{x, y, z}))
+}
+
+// Scale multiplies the current matrix by a scale, like glScale.
+func (s *MatrixStack) Scale(x, y, z float64) {
+	s.Mult(glmath.Scale(glmath.Vec3{x, y, z}))
+}
+
+// Float32 returns the current matrix in single precision.
+func (s *MatrixStack) Float32() [16]float32 { return s.Top().Float32() }
+
+// Uniform uploads the current matrix to the given mat4 shader uniform.
+func (s *MatrixStack) Uniform(loc gl.UniformLocation) {
+	loc.UniformMatrix4fv(false, s.Float32())
+}
+
+// Core profile equivalent of Matrix. Changes to the core matrix stack for
+// Type (gl.PROJECTION or gl.MODELVIEW) are undone on Exit.
+type CoreMatrix struct{ Type gl.GLenum }
+
+func (m CoreMatrix) Enter() { MatrixStackFor(m.Type).Push() }
+func (m CoreMatrix) Exit()  { MatrixStackFor(m.Type).Pop() }
+
+// Core profile equivalent of WindowCoords, operating on the core matrix
+// stacks.
+type CoreWindowCoords struct {
+	NoReset bool
+	Invert  bool
+}
+
+func (wc CoreWindowCoords) Enter() {
+	w, h := GetViewportWHD()
+	Projection.Push()
+	if !wc.NoReset {
+		Projection.LoadIdentity()
+	}
+	if wc.Invert {
+		Projection.Ortho(0, w, h, 0, -1, 1)
+	} else {
+		Projection.Ortho(0, w, 0, h, -1, 1)
+	}
+	ModelView.Push()
+	ModelView.LoadIdentity()
+}
+
+func (wc CoreWindowCoords) Exit() {
+	ModelView.Pop()
+	Projection.Pop()
+}
+
+// Core profile equivalent of Enable. The previous state of each capability
+// is queried on Enter and restored on Exit.
+func CoreEnable(enums ...gl.GLenum) Context {
+	return _coreEnable{enums, true}
+}
+
+// Core profile equivalent of Disable.
+func CoreDisable(enums ...gl.GLenum) Context {
+	return _coreEnable{enums, false}
+}
+
+type _coreEnable struct {
+	enums []gl.GLenum
+	state bool
+}
+
+func (e _coreEnable) Enter() {
+	pushState(queryEnabled(e.enums))
+	for _, item := range e.enums {
+		setEnabled(item, e.state)
+	}
+}
+
+func (e _coreEnable) Exit() {
+	was := popState().([]bool)
+	for i, item := range e.enums {
+		setEnabled(item, was[i])
+	}
+}
+
+func queryEnabled(enums []gl.GLenum) []bool {
+	result := make([]bool, len(enums))
+	for i, item := range enums {
+		result[i] = gl.IsEnabled(item)
+	}
+	return result
+}
+
+func setEnabled(item gl.GLenum, state bool) {
+	if state {
+		gl.Enable(item)
+	} else {
+		gl.Disable(item)
+	}
+}
+
+// Capabilities saved by CoreAttrib for gl.ENABLE_BIT.
+var coreEnableBitCaps = []gl.GLenum{
+	gl.BLEND, gl.CULL_FACE, gl.DEPTH_TEST, gl.SCISSOR_TEST, gl.STENCIL_TEST,
+	gl.POLYGON_OFFSET_FILL, gl.MULTISAMPLE,
+}
+
+// Attrib bits supported by CoreAttrib.
+const coreAttribBits = gl.ENABLE_BIT | gl.COLOR_BUFFER_BIT |
+	gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT | gl.VIEWPORT_BIT |
+	gl.SCISSOR_BIT
+
+// Core profile equivalent of Attrib. Supports gl.ENABLE_BIT,
+// gl.COLOR_BUFFER_BIT, gl.DEPTH_BUFFER_BIT, gl.STENCIL_BUFFER_BIT,
+// gl.VIEWPORT_BIT and gl.SCISSOR_BIT; other bits panic.
+//
+// The state covered by each bit is queried on Enter and restored on Exit.
+// gl.ENABLE_BIT only covers the capabilities which exist in a core profile.
+type CoreAttrib struct{ Bits gl.GLbitfield }
+
+type coreAttribState struct {
+	enabled []bool
+
+	blend      bool
+	blendFunc  [4]int32
+	blendEq    [2]int32
+	colorMask  [4]bool
+	clearColor [4]float32
+
+	depthTest  bool
+	depthFunc  [1]int32
+	depthMask  [1]bool
+	clearDepth [1]float64
+
+	stencilTest  bool
+	stencilFunc  [6]int32 // Front, then back face.
+	stencilOp    [6]int32
+	stencilMask  [2]int32
+	clearStencil [1]int32
+
+	viewport   [4]int32
+	depthRange [2]float64
+
+	scissor     [4]int32
+	scissorTest bool
+}
+
+func (a CoreAttrib) Enter() {
+	if a.Bits&^coreAttribBits != 0 {
+		log.Panicf("glh: CoreAttrib does not support bits %x",
+			a.Bits&^coreAttribBits)
+	}
+
+	s := &coreAttribState{}
+
+	if a.Bits&gl.ENABLE_BIT != 0 {
+		s.enabled = queryEnabled(coreEnableBitCaps)
+	}
+	if a.Bits&gl.COLOR_BUFFER_BIT != 0 {
+		s.blend = gl.IsEnabled(gl.BLEND)
+		gl.GetIntegerv(gl.BLEND_SRC_RGB, s.blendFunc[0:1])
+		gl.GetIntegerv(gl.BLEND_DST_RGB, s.blendFunc[1:2])
+		gl.GetIntegerv(gl.BLEND_SRC_ALPHA, s.blendFunc[2:3])
+		gl.GetIntegerv(gl.BLEND_DST_ALPHA, s.blendFunc[3:4])
+		gl.GetIntegerv(gl.BLEND_EQUATION_RGB, s.blendEq[0:1])
+		gl.GetIntegerv(gl.BLEND_EQUATION_ALPHA, s.blendEq[1:2])
+		gl.GetBooleanv(gl.COLOR_WRITEMASK, s.colorMask[:])
+		gl.GetFloatv(gl.COLOR_CLEAR_VALUE, s.clearColor[:])
+	}
+	if a.Bits&gl.DEPTH_BUFFER_BIT != 0 {
+		s.depthTest = gl.IsEnabled(gl.DEPTH_TEST)
+		gl.GetIntegerv(gl.DEPTH_FUNC, s.depthFunc[:])
+		gl.GetBooleanv(gl.DEPTH_WRITEMASK, s.depthMask[:])
+		gl.GetDoublev(gl.DEPTH_CLEAR_VALUE, s.clearDepth[:])
+	}
+	if a.Bits&gl.STENCIL_BUFFER_BIT != 0 {
+		s.stencilTest = gl.IsEnabled(gl.STENCIL_TEST)
+		gl.GetIntegerv(gl.STENCIL_FUNC, s.stencilFunc[0:1])
+		gl.GetIntegerv(gl.STENCIL_REF, s.stencilFunc[1:2])
+		gl.GetIntegerv(gl.STENCIL_VALUE_MASK, s.stencilFunc[2:3])
+		gl.GetIntegerv(gl.STENCIL_BACK_FUNC, s.stencilFunc[3:4])
+		gl.GetIntegerv(gl.STENCIL_BACK_REF, s.stencilFunc[4:5])
+		gl.GetIntegerv(gl.STENCIL_BACK_VALUE_MASK, s.stencilFunc[5:6])
+		gl.GetIntegerv(gl.STENCIL_FAIL, s.stencilOp[0:1])
+		gl.GetIntegerv(gl.STENCIL_PASS_DEPTH_FAIL, s.stencilOp[1:2])
+		gl.GetIntegerv(gl.STENCIL_PASS_DEPTH_PASS, s.stencilOp[2:3])
+		gl.GetIntegerv(gl.STENCIL_BACK_FAIL, s.stencilOp[3:4])
+		gl.GetIntegerv(gl.STENCIL_BACK_PASS_DEPTH_FAIL, s.stencilOp[4:5])
+		gl.GetIntegerv(gl.STENCIL_BACK_PASS_DEPTH_PASS, s.stencilOp[5:6])
+		gl.GetIntegerv(gl.STENCIL_WRITEMASK, s.stencilMask[0:1])
+		gl.GetIntegerv(gl.STENCIL_BACK_WRITEMASK, s.stencilMask[1:2])
+		gl.GetIntegerv(gl.STENCIL_CLEAR_VALUE, s.clearStencil[:])
+	}
+	if a.Bits&gl.VIEWPORT_BIT != 0 {
+		gl.GetIntegerv(gl.VIEWPORT, s.viewport[:])
+		gl.GetDoublev(gl.DEPTH_RANGE, s.depthRange[:])
+	}
+	if a.Bits&gl.SCISSOR_BIT != 0 {
+		s.scissorTest = gl.IsEnabled(gl.SCISSOR_TEST)
+		gl.GetIntegerv(gl.SCISSOR_BOX, s.scissor[:])
+	}
+
+	pushState(s)
+}
+
+func (a CoreAttrib) Exit() {
+	s := popState().(*coreAttribState)
+
+	if a.Bits&gl.ENABLE_BIT != 0 {
+		for i, item := range coreEnableBitCaps {
+			setEnabled(item, s.enabled[i])
+		}
+	}
+	if a.Bits&gl.COLOR_BUFFER_BIT != 0 {
+		setEnabled(gl.BLEND, s.blend)
+		gl.BlendFuncSeparate(gl.GLenum(s.blendFunc[0]), gl.GLenum(s.blendFunc[1]),
+			gl.GLenum(s.blendFunc[2]), gl.GLenum(s.blendFunc[3]))
+		gl.BlendEquationSeparate(gl.GLenum(s.blendEq[0]), gl.GLenum(s.blendEq[1]))
+		gl.ColorMask(s.colorMask[0], s.colorMask[1], s.colorMask[2], s.colorMask[3])
+		gl.ClearColor(gl.GLclampf(s.clearColor[0]), gl.GLclampf(s.clearColor[1]),
+			gl.GLclampf(s.clearColor[2]), gl.GLclampf(s.clearColor[3]))
+	}
+	if a.Bits&gl.DEPTH_BUFFER_BIT != 0 {
+		setEnabled(gl.DEPTH_TEST, s.depthTest)
+		gl.DepthFunc(gl.GLenum(s.depthFunc[0]))
+		gl.DepthMask(s.depthMask[0])
+		gl.ClearDepth(gl.GLclampd(s.clearDepth[0]))
+	}
+	if a.Bits&gl.STENCIL_BUFFER_BIT != 0 {
+		setEnabled(gl.STENCIL_TEST, s.stencilTest)
+		for i, face := range [2]gl.GLenum{gl.FRONT, gl.BACK} {
+			f, op := s.stencilFunc[3*i:], s.stencilOp[3*i:]
+			gl.StencilFuncSeparate(face, gl.GLenum(f[0]), int(f[1]),
+				uint(uint32(f[2])))
+			gl.StencilOpSeparate(face, gl.GLenum(op[0]), gl.GLenum(op[1]),
+				gl.GLenum(op[2]))
+			gl.StencilMaskSeparate(face, uint(uint32(s.stencilMask[i])))
+		}
+		gl.ClearStencil(int(s.clearStencil[0]))
+	}
+	if a.Bits&gl.VIEWPORT_BIT != 0 {
+		gl.Viewport(int(s.viewport[0]), int(s.viewport[1]),
+			int(s.viewport[2]), int(s.viewport[3]))
+		gl.DepthRange(gl.GLclampd(s.depthRange[0]), gl.GLclampd(s.depthRange[1]))
+	}
+	if a.Bits&gl.SCISSOR_BIT != 0 {
+		setEnabled(gl.SCISSOR_TEST, s.scissorTest)
+		gl.Scissor(int(s.scissor[0]), int(s.scissor[1]),
+			int(s.scissor[2]), int(s.scissor[3]))
+	}
+}
+
+// Core profile equivalent of Primitive. Since there is no glBegin/glEnd,
+// vertices are collected with the Vertex* methods and drawn on Exit from a
+// streaming VBO, feeding the shader attribute at Position.
+// Example:
+//     p := NewCorePrimitive(gl.LINES, prog.GetAttribLocation("position"))
+//     With(p, func() { p.Vertex2f(0, 0); p.Vertex2f(1, 1) })
+type CorePrimitive struct {
+	Type     gl.GLenum
+	Position gl.AttribLocation
+
+	vao      gl.VertexArray
+	vbo      gl.Buffer
+	size     int       // Components per vertex.
+	vertices []float32 // Vertex data collected since Enter.
+}
+
+// NewCorePrimitive creates a new CorePrimitive for the given primitive mode
+// and position attribute.
+func NewCorePrimitive(mode gl.GLenum, position gl.AttribLocation) *CorePrimitive {
+	return &CorePrimitive{Type: mode, Position: position}
+}
+
+func (p *CorePrimitive) Enter() {
+	p.vertices = p.vertices[:0]
+	p.size = 0
+}
+
+func (p *CorePrimitive) Exit() {
+	if len(p.vertices) == 0 {
+		return
+	}
+
+	if p.vbo == 0 {
+		p.vao = gl.GenVertexArray()
+		p.vbo = gl.GenBuffer()
+	}
+
+	bind := Compound(BindVertexArray{p.vao}, BindBuffer{gl.ARRAY_BUFFER, p.vbo})
+	With(bind, func() {
+		gl.BufferData(gl.ARRAY_BUFFER, len(p.vertices)*int(Sizeof(gl.FLOAT)),
+			p.vertices, gl.STREAM_DRAW)
+
+		p.Position.AttribPointer(uint(p.size), gl.FLOAT, false, 0, uintptr(0))
+		p.Position.EnableArray()
+		gl.DrawArrays(p.Type, 0, len(p.vertices)/p.size)
+		p.Position.DisableArray()
+	})
+}
+
+// Release deletes the buffers owned by the primitive.
+func (p *CorePrimitive) Release() {
+	if p.vbo != 0 {
+		p.vbo.Delete()
+		p.vao.Delete()
+		p.vbo, p.vao = 0, 0
+	}
+}
+
+func (p *CorePrimitive) vertex(size int, v ...float32) {
+	if p.size == 0 {
+		p.size = size
+	} else if p.size != size {
+		log.Panicf("glh: mixed vertex sizes %d and %d in CorePrimitive",
+			p.size, size)
+	}
+	p.vertices = append(p.vertices, v...)
+}
+
+func (p *CorePrimitive) Vertex2f(x, y float32)       { p.vertex(2, x, y) }
+func (p *CorePrimitive) Vertex3f(x, y, z float32)    { p.vertex(3, x, y, z) }
+func (p *CorePrimitive) Vertex4f(x, y, z, w float32) { p.vertex(4, x, y, z, w) }
+
+func (p *CorePrimitive) Vertex2i(x, y int) { p.vertex(2, float32(x), float32(y)) }
@@ -0,0 +1,154 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"testing"
+
+	"github.com/go-gl-legacy/glh/glmath"
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+func TestSavedState(t *testing.T) {
+	pushState(1)
+	pushState("two")
+	if v := popState(); v != "two" {
+		t.Errorf("Want two, Have %v", v)
+	}
+	pushState(3)
+	for _, want := range []interface{}{3, 1} {
+		if v := popState(); v != want {
+			t.Errorf("Want %v, Have %v", want, v)
+		}
+	}
+	if len(savedState) != 0 {
+		t.Fatalf("stack not empty: %v", savedState)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("popState on an empty stack did not panic")
+		}
+	}()
+	popState()
+}
+
+func TestMatrixStack(t *testing.T) {
+	s := NewMatrixStack()
+	if s.Depth() != 1 || s.Top() != glmath.Ident4() {
+		t.Fatalf("new stack: depth %d, top %v", s.Depth(), s.Top())
+	}
+
+	s.Translate(1, 2, 3)
+	translated := s.Top()
+	if p := translated.Transform(glmath.Vec3{}); p != (glmath.Vec3{1, 2, 3}) {
+		t.Errorf("Translate: Have %v", p)
+	}
+
+	// Operations post-multiply, like their fixed-function equivalents: the
+	// last one issued applies to vertices first.
+	s.Push()
+	if s.Depth() != 2 || s.Top() != translated {
+		t.Fatalf("Push: depth %d, top %v", s.Depth(), s.Top())
+	}
+	s.Scale(2, 2, 2)
+	if p := s.Top().Transform(glmath.Vec3{1, 1, 1}); p != (glmath.Vec3{3, 4, 5}) {
+		t.Errorf("Translate then Scale: Have %v", p)
+	}
+	s.Mult(glmath.Translate(glmath.Vec3{0, 0, 1}))
+	if p := s.Top().Transform(glmath.Vec3{}); p != (glmath.Vec3{1, 2, 5}) {
+		t.Errorf("Mult: Have %v", p)
+	}
+
+	s.Pop()
+	if s.Depth() != 1 || s.Top() != translated {
+		t.Fatalf("Pop: depth %d, top %v", s.Depth(), s.Top())
+	}
+
+	s.LoadIdentity()
+	s.Ortho(0, 4, 0, 2, -1, 1)
+	if p := s.Top().Transform(glmath.Vec3{4, 2, 0}); p != (glmath.Vec3{1, 1, 0}) {
+		t.Errorf("Ortho: Have %v", p)
+	}
+	if f := s.Float32(); f[0] != 0.5 || f[5] != 1 || f[12] != -1 || f[13] != -1 {
+		t.Errorf("Float32: Have %v", f)
+	}
+
+	m := glmath.Scale(glmath.Vec3{1, 2, 3})
+	s.Load(m)
+	if s.Top() != m {
+		t.Errorf("Load: Want %v, Have %v", m, s.Top())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("popping the last matrix did not panic")
+		}
+	}()
+	s.Pop()
+}
+
+func TestCoreMatrix(t *testing.T) {
+	if MatrixStackFor(gl.PROJECTION) != Projection ||
+		MatrixStackFor(gl.MODELVIEW) != ModelView {
+		t.Fatal("MatrixStackFor returned the wrong stack")
+	}
+
+	depth, top := ModelView.Depth(), ModelView.Top()
+	c := CoreMatrix{gl.MODELVIEW}
+	c.Enter()
+	if ModelView.Depth() != depth+1 {
+		t.Errorf("Enter: Want depth %d, Have %d", depth+1, ModelView.Depth())
+	}
+	ModelView.Translate(1, 0, 0)
+	c.Exit()
+	if ModelView.Depth() != depth || ModelView.Top() != top {
+		t.Errorf("Exit did not restore the stack: depth %d, top %v",
+			ModelView.Depth(), ModelView.Top())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MatrixStackFor accepted gl.TEXTURE")
+		}
+	}()
+	MatrixStackFor(gl.TEXTURE)
+}
+
+// CoreAttrib restores the front and back face stencil state separately, like
+// the StencilFunc and StencilOp contexts.
+func TestCoreAttribStencil(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		gl.StencilFuncSeparate(gl.FRONT, gl.EQUAL, 1, 0x0f)
+		gl.StencilFuncSeparate(gl.BACK, gl.NOTEQUAL, 2, 0xf0)
+		gl.StencilOpSeparate(gl.FRONT, gl.KEEP, gl.INCR, gl.DECR)
+		gl.StencilOpSeparate(gl.BACK, gl.ZERO, gl.INVERT, gl.REPLACE)
+		gl.StencilMaskSeparate(gl.FRONT, 0x3)
+		gl.StencilMaskSeparate(gl.BACK, 0xc)
+
+		masks := func() (m [2]int32) {
+			gl.GetIntegerv(gl.STENCIL_WRITEMASK, m[0:1])
+			gl.GetIntegerv(gl.STENCIL_BACK_WRITEMASK, m[1:2])
+			return m
+		}
+		before, beforeMasks := queryState(), masks()
+
+		With(CoreAttrib{gl.STENCIL_BUFFER_BIT}, func() {
+			gl.StencilFunc(gl.ALWAYS, 0, 0xff)
+			gl.StencilOp(gl.REPLACE, gl.REPLACE, gl.REPLACE)
+			gl.StencilMask(0xff)
+		})
+
+		after := queryState()
+		if after.Stencil != before.Stencil || after.StencilOp != before.StencilOp {
+			t.Errorf("stencil not restored: Want %v %v, Have %v %v",
+				before.Stencil, before.StencilOp, after.Stencil, after.StencilOp)
+		}
+		if m := masks(); m != beforeMasks {
+			t.Errorf("stencil masks: Want %v, Have %v", beforeMasks, m)
+		}
+	}, func() {})
+}
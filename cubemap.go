@@ -0,0 +1,75 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"github.com/go-gl-legacy/glh/glmath"
+	"github.com/go-gl/gl"
+)
+
+// Create a cube map with size by size faces, with initialized storage of the
+// given internal format, such as gl.RGBA. Being a cube map, it must be bound
+// to gl.TEXTURE_CUBE_MAP rather than used as a context.
+func NewCubeTexture(size int, internalformat int) *Texture {
+	texture := &Texture{gl.GenTexture(), size, size}
+	texture.Bind(gl.TEXTURE_CUBE_MAP)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	for face := 0; face < 6; face++ {
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+gl.GLenum(face), 0,
+			internalformat, size, size, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	}
+	texture.Unbind(gl.TEXTURE_CUBE_MAP)
+	return texture
+}
+
+// The view direction and up vector of each cube map face, in the order of
+// gl.TEXTURE_CUBE_MAP_POSITIVE_X onwards. The up vectors follow the cube map
+// texture co-ordinate conventions of the OpenGL specification.
+var cubemapFaces = [6]struct{ dir, up glmath.Vec3 }{
+	{glmath.Vec3{1, 0, 0}, glmath.Vec3{0, -1, 0}},
+	{glmath.Vec3{-1, 0, 0}, glmath.Vec3{0, -1, 0}},
+	{glmath.Vec3{0, 1, 0}, glmath.Vec3{0, 0, 1}},
+	{glmath.Vec3{0, -1, 0}, glmath.Vec3{0, 0, -1}},
+	{glmath.Vec3{0, 0, 1}, glmath.Vec3{0, -1, 0}},
+	{glmath.Vec3{0, 0, -1}, glmath.Vec3{0, -1, 0}},
+}
+
+// CubemapCamera returns the camera at eye which renders the given face of a
+// cube map, numbered from 0 for gl.TEXTURE_CUBE_MAP_POSITIVE_X. It has a 90
+// degree field of view and expects a square viewport.
+func CubemapCamera(eye glmath.Vec3, face int, near, far float64) Camera {
+	f := cubemapFaces[face]
+	return Camera{
+		Eye:    eye,
+		Center: eye.Add(f.dir),
+		Up:     f.up,
+		Fovy:   90,
+		Near:   near,
+		Far:    far,
+	}
+}
+
+// RenderCubemap renders the scene, as seen from eye, into each face of the
+// cube map t. Draw is called once per face with the face's framebuffer bound,
+// cleared, and its camera entered.
+// Example:
+//     env := NewCubeTexture(256, gl.RGBA)
+//     RenderCubemap(env, probe, 0.1, 100, func(face int) { drawScene() })
+func RenderCubemap(t *Texture, eye glmath.Vec3, near, far float64, draw func(face int)) {
+	for face := 0; face < 6; face++ {
+		fb := &Framebuffer{
+			Texture: t,
+			Target:  gl.TEXTURE_CUBE_MAP_POSITIVE_X + gl.GLenum(face),
+		}
+		With(Compound(fb, CubemapCamera(eye, face, near, far)), func() {
+			gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+			draw(face)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl-legacy/glh/glmath"
+)
+
+// Checks each face camera against the cube map face selection table of the
+// OpenGL specification: a direction r is looked up on the face of its major
+// axis ma at s = sc/|ma|, t = tc/|ma|, in [-1, 1].
+func TestCubemapCamera(t *testing.T) {
+	eye := glmath.Vec3{1, 2, 3}
+	lookup := [6]func(r glmath.Vec3) (ma, sc, tc float64){
+		func(r glmath.Vec3) (float64, float64, float64) { return r[0], -r[2], -r[1] },
+		func(r glmath.Vec3) (float64, float64, float64) { return r[0], r[2], -r[1] },
+		func(r glmath.Vec3) (float64, float64, float64) { return r[1], r[0], r[2] },
+		func(r glmath.Vec3) (float64, float64, float64) { return r[1], r[0], -r[2] },
+		func(r glmath.Vec3) (float64, float64, float64) { return r[2], r[0], -r[1] },
+		func(r glmath.Vec3) (float64, float64, float64) { return r[2], -r[0], -r[1] },
+	}
+	offsets := []glmath.Vec3{{0, 0, 0}, {0.5, -0.25, 0.1}, {-0.3, 0.6, -0.7}}
+
+	for face := 0; face < 6; face++ {
+		c := CubemapCamera(eye, face, 0.1, 10)
+		m := c.ProjectionMatrix(1).Mul(c.ViewMatrix())
+		for _, o := range offsets {
+			// A direction whose major axis selects this face.
+			r := cubemapFaces[face].dir.Mul(2).Add(o)
+			ndc := m.Transform(eye.Add(r))
+
+			ma, sc, tc := lookup[face](r)
+			s, tt := sc/math.Abs(ma), tc/math.Abs(ma)
+			if math.Abs(ndc[0]-s) > 1e-9 || math.Abs(ndc[1]-tt) > 1e-9 {
+				t.Errorf("face %d, direction %v: rendered at %v, %v, "+
+					"looked up at %v, %v", face, r, ndc[0], ndc[1], s, tt)
+			}
+		}
+	}
+}
@@ -0,0 +1,439 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/go-gl/gl"
+)
+
+// The effects in this file implement Effect, so they can be used in a
+// PostProcess chain or on their own:
+//     With(&Framebuffer{Texture: out}, func() { fxaa.Apply(scene) })
+// Effects which need intermediate textures own them, so their size must be
+// given on creation. Release deletes the programs and textures.
+
+// Binds a texture to a texture unit other than the current one, for shaders
+// which sample several textures.
+type textureUnit struct {
+	unit    int
+	target  gl.GLenum
+	texture gl.Texture
+}
+
+func (t textureUnit) Enter() {
+	gl.ActiveTexture(gl.TEXTURE0 + gl.GLenum(t.unit))
+	t.texture.Bind(t.target)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+func (t textureUnit) Exit() {
+	gl.ActiveTexture(gl.TEXTURE0 + gl.GLenum(t.unit))
+	t.texture.Unbind(t.target)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// gaussianWeights returns the weights of the centre texel and the texels at
+// increasing distance to each side, for a Gaussian of the given standard
+// deviation in texels. They are normalized so all taps sum to one.
+func gaussianWeights(sigma float64) []float64 {
+	if sigma <= 0 {
+		return []float64{1}
+	}
+	w := make([]float64, int(math.Ceil(3*sigma))+1)
+	sum := 0.0
+	for i := range w {
+		w[i] = math.Exp(-float64(i*i) / (2 * sigma * sigma))
+		if i == 0 {
+			sum += w[i]
+		} else {
+			sum += 2 * w[i]
+		}
+	}
+	for i := range w {
+		w[i] /= sum
+	}
+	return w
+}
+
+// gaussianShader returns a fragment shader blurring along the uniform
+// direction, with the taps unrolled.
+func gaussianShader(sigma float64) string {
+	var buf bytes.Buffer
+	buf.WriteString(`#version 120
+uniform sampler2D source;
+uniform vec2 texelSize;
+uniform vec2 direction;
+varying vec2 texcoord;
+void main() {
+	vec2 step = direction * texelSize;
+`)
+	for i, w := range gaussianWeights(sigma) {
+		if i == 0 {
+			fmt.Fprintf(&buf, "\tvec4 sum = texture2D(source, texcoord) * %f;\n", w)
+			continue
+		}
+		fmt.Fprintf(&buf, "\tsum += (texture2D(source, texcoord + step * %d.0) + "+
+			"texture2D(source, texcoord - step * %d.0)) * %f;\n", i, i, w)
+	}
+	buf.WriteString("\tgl_FragColor = sum;\n}\n")
+	return buf.String()
+}
+
+// A GaussianBlur blurs in two separable passes, horizontally into an
+// intermediate texture and then vertically.
+type GaussianBlur struct {
+	shader   *ShaderEffect
+	temp     *Texture
+	vertical bool
+}
+
+// NewGaussianBlur creates a blur with a standard deviation of sigma texels,
+// for sources of w by h texels. The intermediate texture has the given
+// internal format.
+func NewGaussianBlur(w, h int, sigma float64, internalformat int) *GaussianBlur {
+	b := &GaussianBlur{temp: NewTexture(w, h)}
+	b.temp.InitFormat(internalformat)
+	b.shader = NewShaderEffect(gaussianShader(sigma), func(p gl.Program) {
+		if b.vertical {
+			p.GetUniformLocation("direction").Uniform2f(0, 1)
+		} else {
+			p.GetUniformLocation("direction").Uniform2f(1, 0)
+		}
+	})
+	return b
+}
+
+func (b *GaussianBlur) Apply(src *Texture) {
+	// The intermediate Framebuffer restores the caller's on Exit, so the
+	// vertical pass draws to the caller's target.
+	b.vertical = false
+	With(&Framebuffer{Texture: b.temp}, func() { b.shader.Apply(src) })
+	b.vertical = true
+	b.shader.Apply(b.temp)
+}
+
+func (b *GaussianBlur) Release() {
+	b.shader.Release()
+	b.temp.Delete()
+}
+
+const bloomThresholdShader = `#version 120
+uniform sampler2D source;
+uniform float threshold;
+varying vec2 texcoord;
+void main() {
+	vec4 c = texture2D(source, texcoord);
+	float l = dot(c.rgb, vec3(0.2126, 0.7152, 0.0722));
+	gl_FragColor = vec4(c.rgb * max(l - threshold, 0.0) / max(l, 1e-4), 1.0);
+}
+`
+
+const bloomCombineShader = `#version 120
+uniform sampler2D source;
+uniform sampler2D bloom;
+uniform float intensity;
+varying vec2 texcoord;
+void main() {
+	vec4 c = texture2D(source, texcoord);
+	gl_FragColor = vec4(c.rgb + intensity * texture2D(bloom, texcoord).rgb, c.a);
+}
+`
+
+// A Bloom adds a blurred copy of the bright parts of the image to it.
+type Bloom struct {
+	Threshold float32 // Luminance above which texels bloom.
+	Intensity float32 // Scale of the bloom added to the image.
+
+	threshold, combine *ShaderEffect
+	blur               *GaussianBlur
+	bright, blurred    *Texture
+}
+
+// NewBloom creates a bloom for sources of w by h texels, blurred with a
+// standard deviation of sigma texels. The intermediate textures have the
+// given internal format.
+func NewBloom(w, h int, sigma float64, internalformat int) *Bloom {
+	b := &Bloom{
+		Threshold: 1,
+		Intensity: 1,
+		blur:      NewGaussianBlur(w, h, sigma, internalformat),
+		bright:    NewTexture(w, h),
+		blurred:   NewTexture(w, h),
+	}
+	b.bright.InitFormat(internalformat)
+	b.blurred.InitFormat(internalformat)
+	b.threshold = NewShaderEffect(bloomThresholdShader, func(p gl.Program) {
+		p.GetUniformLocation("threshold").Uniform1f(b.Threshold)
+	})
+	b.combine = NewShaderEffect(bloomCombineShader, func(p gl.Program) {
+		p.GetUniformLocation("bloom").Uniform1i(1)
+		p.GetUniformLocation("intensity").Uniform1f(b.Intensity)
+	})
+	return b
+}
+
+func (b *Bloom) Apply(src *Texture) {
+	With(&Framebuffer{Texture: b.bright}, func() { b.threshold.Apply(src) })
+	With(&Framebuffer{Texture: b.blurred}, func() { b.blur.Apply(b.bright) })
+	With(textureUnit{1, gl.TEXTURE_2D, b.blurred.Texture}, func() {
+		b.combine.Apply(src)
+	})
+}
+
+func (b *Bloom) Release() {
+	b.threshold.Release()
+	b.combine.Release()
+	b.blur.Release()
+	b.bright.Delete()
+	b.blurred.Delete()
+}
+
+const fxaaShader = `#version 120
+uniform sampler2D source;
+uniform vec2 texelSize;
+uniform float spanMax;
+uniform float reduceMul;
+uniform float reduceMin;
+varying vec2 texcoord;
+void main() {
+	vec3 luma = vec3(0.299, 0.587, 0.114);
+	float lumaNW = dot(texture2D(source, texcoord + vec2(-1.0, -1.0) * texelSize).rgb, luma);
+	float lumaNE = dot(texture2D(source, texcoord + vec2(1.0, -1.0) * texelSize).rgb, luma);
+	float lumaSW = dot(texture2D(source, texcoord + vec2(-1.0, 1.0) * texelSize).rgb, luma);
+	float lumaSE = dot(texture2D(source, texcoord + vec2(1.0, 1.0) * texelSize).rgb, luma);
+	vec4 rgbaM = texture2D(source, texcoord);
+	float lumaM = dot(rgbaM.rgb, luma);
+	float lumaMin = min(lumaM, min(min(lumaNW, lumaNE), min(lumaSW, lumaSE)));
+	float lumaMax = max(lumaM, max(max(lumaNW, lumaNE), max(lumaSW, lumaSE)));
+
+	vec2 dir = vec2(-((lumaNW + lumaNE) - (lumaSW + lumaSE)),
+	                ((lumaNW + lumaSW) - (lumaNE + lumaSE)));
+	float dirReduce = max((lumaNW + lumaNE + lumaSW + lumaSE) * 0.25 * reduceMul,
+	                      reduceMin);
+	float rcpDirMin = 1.0 / (min(abs(dir.x), abs(dir.y)) + dirReduce);
+	dir = clamp(dir * rcpDirMin, -spanMax, spanMax) * texelSize;
+
+	vec3 rgbA = 0.5 * (
+		texture2D(source, texcoord + dir * (1.0 / 3.0 - 0.5)).rgb +
+		texture2D(source, texcoord + dir * (2.0 / 3.0 - 0.5)).rgb);
+	vec3 rgbB = rgbA * 0.5 + 0.25 * (
+		texture2D(source, texcoord - dir * 0.5).rgb +
+		texture2D(source, texcoord + dir * 0.5).rgb);
+	float lumaB = dot(rgbB, luma);
+	if (lumaB < lumaMin || lumaB > lumaMax) {
+		gl_FragColor = vec4(rgbA, rgbaM.a);
+	} else {
+		gl_FragColor = vec4(rgbB, rgbaM.a);
+	}
+}
+`
+
+// An FXAA applies fast approximate anti-aliasing. It expects a source in
+// gamma space, so apply it after tone mapping.
+type FXAA struct {
+	SpanMax   float32 // Maximum length of the edge search, in texels.
+	ReduceMul float32 // Scale of the search direction reduction.
+	ReduceMin float32 // Minimum search direction reduction.
+
+	shader *ShaderEffect
+}
+
+// NewFXAA creates an FXAA effect with the usual defaults.
+func NewFXAA() *FXAA {
+	f := &FXAA{SpanMax: 8, ReduceMul: 1.0 / 8, ReduceMin: 1.0 / 128}
+	f.shader = NewShaderEffect(fxaaShader, func(p gl.Program) {
+		p.GetUniformLocation("spanMax").Uniform1f(f.SpanMax)
+		p.GetUniformLocation("reduceMul").Uniform1f(f.ReduceMul)
+		p.GetUniformLocation("reduceMin").Uniform1f(f.ReduceMin)
+	})
+	return f
+}
+
+func (f *FXAA) Apply(src *Texture) { f.shader.Apply(src) }
+func (f *FXAA) Release()           { f.shader.Release() }
+
+// A tone mapping operator, mapping high dynamic range colors into [0, 1].
+type ToneMapOperator int
+
+const (
+	Reinhard ToneMapOperator = iota // c / (1 + c)
+	ACES                            // Narkowicz's fit of the ACES filmic curve.
+)
+
+const toneMapShader = `#version 120
+uniform sampler2D source;
+uniform int operator;
+uniform float exposure;
+uniform float gamma;
+varying vec2 texcoord;
+void main() {
+	vec4 c = texture2D(source, texcoord);
+	vec3 x = c.rgb * exposure;
+	if (operator == 0) {
+		x = x / (1.0 + x);
+	} else {
+		x = clamp((x * (2.51 * x + 0.03)) / (x * (2.43 * x + 0.59) + 0.14),
+		          0.0, 1.0);
+	}
+	gl_FragColor = vec4(pow(x, vec3(1.0 / gamma)), c.a);
+}
+`
+
+// A ToneMap maps a high dynamic range source, such as a gl.RGBA16F texture,
+// to displayable colors and applies gamma correction.
+type ToneMap struct {
+	Operator ToneMapOperator
+	Exposure float32 // Scale applied before the operator.
+	Gamma    float32 // Display gamma, 1 for none.
+
+	shader *ShaderEffect
+}
+
+// NewToneMap creates a tone mapping effect with unit exposure and a gamma of
+// 2.2.
+func NewToneMap(operator ToneMapOperator) *ToneMap {
+	t := &ToneMap{Operator: operator, Exposure: 1, Gamma: 2.2}
+	t.shader = NewShaderEffect(toneMapShader, func(p gl.Program) {
+		p.GetUniformLocation("operator").Uniform1i(int(t.Operator))
+		p.GetUniformLocation("exposure").Uniform1f(t.Exposure)
+		p.GetUniformLocation("gamma").Uniform1f(t.Gamma)
+	})
+	return t
+}
+
+func (t *ToneMap) Apply(src *Texture) { t.shader.Apply(src) }
+func (t *ToneMap) Release()           { t.shader.Release() }
+
+// lutData returns the texels of a size^3 RGB lookup table which maps each
+// color through fn, red varying fastest. Components are in [0, 1], so size
+// must be at least 2.
+func lutData(size int, fn func(r, g, b float64) (float64, float64, float64)) []uint8 {
+	quantize := func(x float64) uint8 {
+		return uint8(math.Max(0, math.Min(1, x))*255 + 0.5)
+	}
+	data := make([]uint8, 0, size*size*size*3)
+	scale := 1 / float64(size-1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				rr, gg, bb := fn(float64(r)*scale, float64(g)*scale,
+					float64(b)*scale)
+				data = append(data, quantize(rr), quantize(gg), quantize(bb))
+			}
+		}
+	}
+	return data
+}
+
+// NewColorLUT creates a 3D lookup table texture of size^3 texels for
+// ColorGrade, mapping each color through fn. Components are in [0, 1]. Size
+// must be at least 2.
+func NewColorLUT(size int, fn func(r, g, b float64) (float64, float64, float64)) gl.Texture {
+	if size < 2 {
+		log.Panicf("NewColorLUT: size %d, must be at least 2", size)
+	}
+	lut := gl.GenTexture()
+	lut.Bind(gl.TEXTURE_3D)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	// Rows of size*3 bytes are tightly packed, rather than aligned to the
+	// default of 4 bytes.
+	var alignment [1]int32
+	gl.GetIntegerv(gl.UNPACK_ALIGNMENT, alignment[:])
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage3D(gl.TEXTURE_3D, 0, gl.RGB8, size, size, size, 0, gl.RGB,
+		gl.UNSIGNED_BYTE, lutData(size, fn))
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, int(alignment[0]))
+	lut.Unbind(gl.TEXTURE_3D)
+	return lut
+}
+
+const colorGradeShader = `#version 120
+uniform sampler2D source;
+uniform sampler3D lut;
+uniform float lutSize;
+uniform float intensity;
+varying vec2 texcoord;
+void main() {
+	vec4 c = texture2D(source, texcoord);
+	// Sample texel centres, so that 0 and 1 map to the first and last texel.
+	vec3 coord = clamp(c.rgb, 0.0, 1.0) * ((lutSize - 1.0) / lutSize) +
+		0.5 / lutSize;
+	vec3 graded = texture3D(lut, coord).rgb;
+	gl_FragColor = vec4(mix(c.rgb, graded, intensity), c.a);
+}
+`
+
+// A ColorGrade maps colors through a 3D lookup table, such as one created
+// by NewColorLUT. The LUT is not owned by the effect.
+type ColorGrade struct {
+	LUT       gl.Texture
+	Size      int     // Texels along each side of the LUT.
+	Intensity float32 // Blend between the original (0) and graded (1) colors.
+
+	shader *ShaderEffect
+}
+
+// NewColorGrade creates a color grading effect using lut, of size^3 texels.
+func NewColorGrade(lut gl.Texture, size int) *ColorGrade {
+	c := &ColorGrade{LUT: lut, Size: size, Intensity: 1}
+	c.shader = NewShaderEffect(colorGradeShader, func(p gl.Program) {
+		p.GetUniformLocation("lut").Uniform1i(1)
+		p.GetUniformLocation("lutSize").Uniform1f(float32(c.Size))
+		p.GetUniformLocation("intensity").Uniform1f(c.Intensity)
+	})
+	return c
+}
+
+func (c *ColorGrade) Apply(src *Texture) {
+	With(textureUnit{1, gl.TEXTURE_3D, c.LUT}, func() { c.shader.Apply(src) })
+}
+
+func (c *ColorGrade) Release() { c.shader.Release() }
+
+const vignetteShader = `#version 120
+uniform sampler2D source;
+uniform float radius;
+uniform float softness;
+uniform float intensity;
+varying vec2 texcoord;
+void main() {
+	vec4 c = texture2D(source, texcoord);
+	float d = distance(texcoord, vec2(0.5));
+	float v = 1.0 - smoothstep(radius - softness, radius, d);
+	gl_FragColor = vec4(c.rgb * mix(1.0, v, intensity), c.a);
+}
+`
+
+// A Vignette darkens the image towards its corners.
+type Vignette struct {
+	Radius    float32 // Distance from the centre where darkening is complete.
+	Softness  float32 // Width of the transition, inwards from Radius.
+	Intensity float32 // Darkness at Radius, from 0 (none) to 1 (black).
+
+	shader *ShaderEffect
+}
+
+// NewVignette creates a vignette effect with moderate defaults.
+func NewVignette() *Vignette {
+	v := &Vignette{Radius: 0.75, Softness: 0.45, Intensity: 0.5}
+	v.shader = NewShaderEffect(vignetteShader, func(p gl.Program) {
+		p.GetUniformLocation("radius").Uniform1f(v.Radius)
+		p.GetUniformLocation("softness").Uniform1f(v.Softness)
+		p.GetUniformLocation("intensity").Uniform1f(v.Intensity)
+	})
+	return v
+}
+
+func (v *Vignette) Apply(src *Texture) { v.shader.Apply(src) }
+func (v *Vignette) Release()           { v.shader.Release() }
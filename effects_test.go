@@ -0,0 +1,128 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+func TestGaussianWeights(t *testing.T) {
+	for _, sigma := range []float64{0, 0.5, 1, 2.5, 4} {
+		w := gaussianWeights(sigma)
+		sum := w[0]
+		for i := 1; i < len(w); i++ {
+			sum += 2 * w[i]
+			if w[i] >= w[i-1] {
+				t.Errorf("sigma %v: weights not decreasing: %v", sigma, w)
+			}
+		}
+		if math.Abs(sum-1) > 1e-12 {
+			t.Errorf("sigma %v: weights sum to %v", sigma, sum)
+		}
+	}
+
+	if n := len(gaussianWeights(2)); n != 7 {
+		t.Errorf("sigma 2: %d weights, want 7", n)
+	}
+	if s := gaussianShader(2); strings.Count(s, "texture2D") != 13 {
+		t.Errorf("sigma 2 shader does not have 13 taps:\n%s", s)
+	}
+}
+
+func TestLUTData(t *testing.T) {
+	identity := func(r, g, b float64) (float64, float64, float64) { return r, g, b }
+	data := lutData(3, identity)
+	if len(data) != 3*3*3*3 {
+		t.Fatalf("got %d bytes", len(data))
+	}
+	// Texel (r, g, b) = (2, 0, 1), red varying fastest.
+	i := ((1*3+0)*3 + 2) * 3
+	if data[i] != 255 || data[i+1] != 0 || data[i+2] != 128 {
+		t.Errorf("texel (2, 0, 1) = %v", data[i:i+3])
+	}
+
+	clamp := lutData(2, func(r, g, b float64) (float64, float64, float64) {
+		return -1, 2, 0.5
+	})
+	if clamp[0] != 0 || clamp[1] != 255 || clamp[2] != 128 {
+		t.Errorf("out of range components not clamped: %v", clamp[:3])
+	}
+
+	// Rows of 17 texels are 51 bytes, tightly packed with no padding: texel
+	// (r, g, b) starts at ((b*size+g)*size+r)*3. The corners map to 0 and 255.
+	const size = 17
+	data = lutData(size, identity)
+	if len(data) != size*size*size*3 {
+		t.Fatalf("size %d: got %d bytes", size, len(data))
+	}
+	for _, c := range [][3]int{
+		{0, 0, 0}, {16, 0, 0}, {0, 16, 0}, {0, 0, 16},
+		{16, 16, 0}, {16, 0, 16}, {0, 16, 16}, {16, 16, 16},
+		{1, 2, 3}, {5, 0, 16},
+	} {
+		i := ((c[2]*size+c[1])*size + c[0]) * 3
+		for j := range c {
+			want := uint8(float64(c[j])/(size-1)*255 + 0.5)
+			if data[i+j] != want {
+				t.Errorf("texel %v = %v, want component %d = %d",
+					c, data[i:i+3], j, want)
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewColorLUT accepted size 1")
+		}
+	}()
+	NewColorLUT(1, identity)
+}
+
+// Effects with intermediate passes run inside the caller's Framebuffer.
+// Their last pass must still draw to it, and leave it bound.
+func TestEffectsInFramebuffer(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		const w, h = 16, 16
+
+		white := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(white, white.Bounds(), image.NewUniform(color.White),
+			image.ZP, draw.Src)
+		src := NewTexture(w, h)
+		src.FromImageRGBA(white, 0)
+		defer src.Delete()
+
+		blur := NewGaussianBlur(w, h, 2, gl.RGBA8)
+		defer blur.Release()
+		bloom := NewBloom(w, h, 2, gl.RGBA8)
+		defer bloom.Release()
+
+		for _, e := range []Effect{blur, bloom} {
+			dst := NewTexture(w, h)
+			dst.Init()
+
+			fb := &Framebuffer{Texture: dst}
+			With(fb, func() {
+				e.Apply(src)
+				if b := getBinding(gl.DRAW_FRAMEBUFFER_BINDING); gl.Framebuffer(b) != fb.fbo {
+					t.Errorf("%T: framebuffer %d bound after Apply, want %d", e, b, fb.fbo)
+				}
+			})
+
+			// Blurring or blooming a uniform white image leaves it white.
+			if c := dst.AsImage().RGBAAt(w/2, h/2); c.R < 250 || c.G < 250 || c.B < 250 {
+				t.Errorf("%T: target pixel %v, want white", e, c)
+			}
+			dst.Delete()
+		}
+	}, func() {})
+}
@@ -5,87 +5,614 @@
 package glh
 
 import (
+	"container/list"
+	"fmt"
 	"image"
 	"log"
 
 	"github.com/go-gl/gl"
 )
 
-// Mapping from texture dimensions onto ready made framebuffer/renderbuffer
-// therefore we only construct one per image dimensions
-// This number should be less than O(1000) otherwise opengl throws OUT_OF_MEMORY
-// on some cards
-var framebuffers map[image.Point]*fborbo = make(map[image.Point]*fborbo)
-
+// A framebuffer object with its renderbuffers, reused through a
+// FramebufferPool by all Framebuffer contexts with the same key.
 type fborbo struct {
-	fbo gl.Framebuffer
-	rbo gl.Renderbuffer
+	fbo    gl.Framebuffer
+	rbo    gl.Renderbuffer   // Depth and stencil, if any.
+	crbos  []gl.Renderbuffer // Colors, multisampled framebuffers only.
+	colors int               // Number of color attachments set by the last user.
+
+	key  fbKey
+	elem *list.Element // Position in the pool's LRU list when idle.
 }
 
-// Internal function to generate a framebuffer/renderbuffer of the correct
-// dimensions exactly once per execution
-func getFBORBO(t *Texture) *fborbo {
-	p := image.Point{t.W, t.H}
-	result, ok := framebuffers[p]
-	if ok {
-		return result
+// Framebuffers are reused by all Framebuffer contexts with equal keys.
+type fbKey struct {
+	size    image.Point
+	depth   DepthFormat
+	samples int       // Zero for framebuffers which render to textures.
+	colors  int       // Number of color renderbuffers, if multisampled.
+	color   gl.GLenum // Format of the color renderbuffers, if multisampled.
+}
+
+// DepthFormat selects the format of the depth and stencil renderbuffer of a
+// Framebuffer.
+type DepthFormat int
+
+const (
+	DepthDefault     DepthFormat = iota // gl.DEPTH_COMPONENT, precision chosen by the driver.
+	DepthNone                           // No depth or stencil buffer.
+	Depth16                             // gl.DEPTH_COMPONENT16
+	Depth24                             // gl.DEPTH_COMPONENT24
+	Depth32F                            // gl.DEPTH_COMPONENT32F
+	Depth24Stencil8                     // gl.DEPTH24_STENCIL8, with a stencil buffer.
+	Depth32                             // gl.DEPTH_COMPONENT32
+	Depth32FStencil8                    // gl.DEPTH32F_STENCIL8, with a stencil buffer.
+)
+
+func (f DepthFormat) internalFormat() gl.GLenum {
+	switch f {
+	case DepthDefault:
+		return gl.DEPTH_COMPONENT
+	case Depth16:
+		return gl.DEPTH_COMPONENT16
+	case Depth24:
+		return gl.DEPTH_COMPONENT24
+	case Depth32F:
+		return gl.DEPTH_COMPONENT32F
+	case Depth24Stencil8:
+		return gl.DEPTH24_STENCIL8
+	case Depth32:
+		return gl.DEPTH_COMPONENT32
+	case Depth32FStencil8:
+		return gl.DEPTH32F_STENCIL8
 	}
+	log.Panicf("glh: invalid depth format %d", f)
+	return 0
+}
 
-	result = &fborbo{}
+func (f DepthFormat) attachment() gl.GLenum {
+	if f == Depth24Stencil8 || f == Depth32FStencil8 {
+		return gl.DEPTH_STENCIL_ATTACHMENT
+	}
+	return gl.DEPTH_ATTACHMENT
+}
+
+// depthFormatOf returns the DepthFormat of a renderbuffer which matches a
+// texture of the given depth or depth-stencil internal format.
+func depthFormatOf(internalformat gl.GLenum) (DepthFormat, bool) {
+	switch internalformat {
+	case gl.DEPTH_COMPONENT:
+		return DepthDefault, true
+	case gl.DEPTH_COMPONENT16:
+		return Depth16, true
+	case gl.DEPTH_COMPONENT24:
+		return Depth24, true
+	case gl.DEPTH_COMPONENT32:
+		return Depth32, true
+	case gl.DEPTH_COMPONENT32F:
+		return Depth32F, true
+	case gl.DEPTH_STENCIL, gl.DEPTH24_STENCIL8:
+		return Depth24Stencil8, true
+	case gl.DEPTH32F_STENCIL8:
+		return Depth32FStencil8, true
+	}
+	return DepthNone, false
+}
+
+// Internal function to generate a framebuffer with renderbuffers for key.
+// Unless multisampled, only the depth renderbuffer is created; the textures
+// are attached by Framebuffer.Enter.
+func newFBORBO(key fbKey) *fborbo {
+	result := &fborbo{key: key}
 
-	result.rbo = gl.GenRenderbuffer()
-	OpenGLSentinel()
 	result.fbo = gl.GenFramebuffer()
 	OpenGLSentinel()
-
 	result.fbo.Bind()
 
-	result.rbo.Bind()
-	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT, t.W, t.H)
-	result.rbo.Unbind()
+	w, h := key.size.X, key.size.Y
+	storage := func(format, attachment gl.GLenum) gl.Renderbuffer {
+		rbo := gl.GenRenderbuffer()
+		rbo.Bind()
+		if key.samples > 0 {
+			gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, key.samples,
+				format, w, h)
+		} else {
+			gl.RenderbufferStorage(gl.RENDERBUFFER, format, w, h)
+		}
+		rbo.Unbind()
+		rbo.FramebufferRenderbuffer(gl.FRAMEBUFFER, attachment, gl.RENDERBUFFER)
+		return rbo
+	}
 
-	result.rbo.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT,
-		gl.RENDERBUFFER)
+	if key.depth != DepthNone {
+		result.rbo = storage(key.depth.internalFormat(), key.depth.attachment())
+	}
+	for i := 0; i < key.colors; i++ {
+		result.crbos = append(result.crbos,
+			storage(key.color, gl.COLOR_ATTACHMENT0+gl.GLenum(i)))
+	}
+	OpenGLSentinel()
 
 	result.fbo.Unbind()
-
-	framebuffers[image.Point{t.W, t.H}] = result
 	return result
 }
 
+// deleteFBORBO deletes the framebuffer and its renderbuffers.
+func deleteFBORBO(f *fborbo) {
+	f.fbo.Delete()
+	f.rbo.Delete()
+	for _, rbo := range f.crbos {
+		rbo.Delete()
+	}
+}
+
 // During this context, OpenGL drawing operations will instead render to `*Texture`.
 // Example usage:
-//     With(Framebuffer{my_texture}, func() { .. operations to render to texture .. })
+//     With(&Framebuffer{Texture: t}, func() { .. operations to render to texture .. })
+//
+// To render to several textures at once, for example for deferred shading,
+// list them in Colors instead. They are attached to gl.COLOR_ATTACHMENT0
+// onwards in order, and selected with glDrawBuffers:
+//     With(&Framebuffer{Colors: []*Texture{albedo, normal}, Depth: depth}, ..)
+//
+// Unless Depth or DepthStencil is given, an internal renderbuffer of
+// DepthFormat is used. Select Depth24Stencil8 for a stencil buffer:
+//     With(&Framebuffer{Texture: t, DepthFormat: Depth24Stencil8}, ..)
 //
-// Internally this will permanently allocate a framebuffer with the appropriate
-// dimensions. Beware that using a large number of textures with differing sizes
-// will therefore cause some graphics cards to run out of memory.
+// All attachments must have the same dimensions. A framebuffer with
+// only a depth texture, see NewDepthTexture, renders no color:
+//     With(&Framebuffer{Depth: shadow}, func() { .. draw occluders .. })
+//
+// Cube map faces and layers of array textures are selected with Target and
+// Layer, see also RenderCubemap:
+//     With(&Framebuffer{Texture: cascades, Target: gl.TEXTURE_2D_ARRAY, Layer: 2}, ..)
+//
+// The viewport is set to cover the texture's mipmap Level, so WindowCoords
+// maps to texture pixels, and restored on Exit.
+//
+// Set Samples for antialiased rendering. The multisampled result is resolved
+// into the textures on Exit:
+//     With(&Framebuffer{Texture: t, Samples: 4}, ..)
+//
+// Enter panics with a *FramebufferError if OpenGL reports the framebuffer
+// incomplete, or with a plain error if the attachment fields are invalid.
+// Validate reports the same without entering the context. Exit restores
+// the framebuffers bound before Enter, so Framebuffers may be nested.
+//
+// Internally the framebuffer objects are taken from a FramebufferPool, which
+// reuses them between all textures of the same dimensions and formats.
 type Framebuffer struct {
 	*Texture
 	*fborbo
 	Level int
+
+	// Texture target of the attachments. Zero means gl.TEXTURE_2D. Set a
+	// gl.TEXTURE_CUBE_MAP_* face to render to that face of cube maps, or
+	// gl.TEXTURE_2D_ARRAY, gl.TEXTURE_3D or gl.TEXTURE_CUBE_MAP_ARRAY to
+	// render to Layer.
+	Target gl.GLenum
+	Layer  int
+
+	// Color attachments in order. Overrides Texture if not empty.
+	Colors []*Texture
+
+	// Optional depth texture, attached in place of the internal
+	// depth renderbuffer.
+	Depth *Texture
+
+	// Optional packed depth-stencil texture, attached to
+	// gl.DEPTH_STENCIL_ATTACHMENT in place of the internal renderbuffer.
+	DepthStencil *Texture
+
+	// Format of the internal renderbuffer, if neither Depth nor
+	// DepthStencil is given.
+	DepthFormat DepthFormat
+
+	// Internal format of the multisampled color renderbuffers. Defaults to
+	// that of the color textures, which they are resolved into and must
+	// match. See Texture.InitFormat.
+	ColorFormat gl.GLenum
+
+	// Samples per pixel. If non-zero, drawing goes to multisampled
+	// renderbuffers which are resolved into the textures on Exit.
+	// Clamped to MaxSamples.
+	Samples int
+	ms      *fborbo
+
+	// The pool providing the framebuffer objects. If nil,
+	// DefaultFramebufferPool is used.
+	Pool *FramebufferPool
+
+	// Framebuffers bound before Enter, restored on Exit.
+	prevRead, prevDraw gl.Framebuffer
+}
+
+// colors returns the color attachments in order.
+func (b *Framebuffer) colors() []*Texture {
+	if len(b.Colors) > 0 {
+		return b.Colors
+	}
+	if b.Texture == nil {
+		return nil
+	}
+	return []*Texture{b.Texture}
+}
+
+// size returns a texture with the dimensions of the attachments.
+func (b *Framebuffer) size() *Texture {
+	if colors := b.colors(); len(colors) > 0 {
+		return colors[0]
+	}
+	if b.Depth != nil {
+		return b.Depth
+	}
+	return b.DepthStencil
+}
+
+// checkAttachments verifies that all attachments are present and share the
+// same dimensions.
+func (b *Framebuffer) checkAttachments() error {
+	if len(b.Colors) > 0 && b.Texture != nil && b.Texture != b.Colors[0] {
+		return fmt.Errorf("Framebuffer: both Texture and Colors given")
+	}
+	if b.Depth != nil && b.DepthStencil != nil {
+		return fmt.Errorf("Framebuffer: both Depth and DepthStencil given")
+	}
+
+	colors := b.colors()
+	if b.size() == nil {
+		return fmt.Errorf("Framebuffer: no attachment")
+	}
+
+	w, h := b.size().W, b.size().H
+	check := func(name string, t *Texture) error {
+		if t == nil {
+			return fmt.Errorf("Framebuffer: %s is nil", name)
+		}
+		if t.W != w || t.H != h {
+			return fmt.Errorf("Framebuffer: %s is %dx%d, expected %dx%d",
+				name, t.W, t.H, w, h)
+		}
+		return nil
+	}
+
+	for i, t := range colors {
+		if err := check(fmt.Sprintf("color attachment %d", i), t); err != nil {
+			return err
+		}
+	}
+	if b.Depth != nil {
+		if err := check("depth attachment", b.Depth); err != nil {
+			return err
+		}
+	}
+	if b.DepthStencil != nil {
+		if err := check("depth-stencil attachment", b.DepthStencil); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (b *Framebuffer) Enter() {
-	if b.fborbo == nil {
-		b.fborbo = getFBORBO(b.Texture)
+	if err := b.bind(); err != nil {
+		panic(err)
+	}
+	w, h := levelSize(b.size(), b.Level)
+	Viewport{0, 0, w, h}.Enter()
+}
+
+// Validate checks that the framebuffer is complete, without entering it.
+// If the attachment fields are invalid, for example of different sizes, it
+// returns a plain error. If OpenGL reports the framebuffer incomplete, it
+// returns a *FramebufferError describing the attachments. The framebuffer
+// bindings are restored.
+func (b *Framebuffer) Validate() error {
+	if b.fborbo != nil {
+		return nil // Inside the context, so validated by Enter.
+	}
+
+	if err := b.bind(); err != nil {
+		return err
+	}
+	b.unbind()
+	return nil
+}
+
+// bind acquires the framebuffer objects, binds them and sets the
+// attachments. If it fails, nothing remains bound or acquired.
+func (b *Framebuffer) bind() error {
+	if err := b.checkAttachments(); err != nil {
+		return err
 	}
 
+	b.prevRead = gl.Framebuffer(getBinding(gl.READ_FRAMEBUFFER_BINDING))
+	b.prevDraw = gl.Framebuffer(getBinding(gl.DRAW_FRAMEBUFFER_BINDING))
+
+	pool := b.pool()
+	t := b.size()
+	depth := b.DepthFormat
+	if b.Depth != nil || b.DepthStencil != nil {
+		depth = DepthNone
+	}
+	b.fborbo = pool.acquire(fbKey{size: image.Point{t.W, t.H}, depth: depth})
+
 	b.fbo.Bind()
+	b.attach(gl.FRAMEBUFFER, b.fborbo)
+	err := b.checkStatus(gl.FRAMEBUFFER)
+
+	if err == nil && b.Samples > 0 {
+		err = b.bindMultisampled(pool)
+	}
+
+	if err != nil {
+		b.unbind()
+	}
+	return err
+}
+
+// unbind restores the previous bindings and releases the framebuffer
+// objects.
+func (b *Framebuffer) unbind() {
+	b.prevRead.BindTarget(gl.READ_FRAMEBUFFER)
+	b.prevDraw.BindTarget(gl.DRAW_FRAMEBUFFER)
+	if b.ms != nil {
+		b.pool().release(b.ms)
+		b.ms = nil
+	}
+	b.pool().release(b.fborbo)
+	b.fborbo = nil
+}
+
+// bindMultisampled acquires and binds the multisampled framebuffer, whose
+// renderbuffers match the formats of the textures.
+func (b *Framebuffer) bindMultisampled(pool *FramebufferPool) error {
+	samples := b.Samples
+	if max := MaxSamples(); samples > max {
+		samples = max
+	}
+	depth, color, err := b.msFormats(func(t *Texture) gl.GLenum {
+		return textureFormat(t, b.Target, b.Level)
+	})
+	if err != nil {
+		return err
+	}
+
+	colors := b.colors()
+	w, h := levelSize(b.size(), b.Level)
+	b.ms = pool.acquire(fbKey{image.Point{w, h}, depth, samples, len(colors),
+		color})
+	b.ms.fbo.Bind()
+	setDrawBuffers(gl.FRAMEBUFFER, len(colors))
+	return b.checkStatus(gl.FRAMEBUFFER)
+}
+
+// msFormats returns the formats of the multisampled depth and color
+// renderbuffers. Resolving requires them to match the textures, whose
+// internal formats are queried with format.
+func (b *Framebuffer) msFormats(format func(*Texture) gl.GLenum) (
+	depth DepthFormat, color gl.GLenum, err error) {
 
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D,
-		b.Texture.Texture, b.Level)
+	depth = b.DepthFormat
+	if depth == DepthDefault {
+		depth = Depth24
+	}
+	t := b.DepthStencil
+	if t == nil {
+		t = b.Depth
+	}
+	if t != nil {
+		f := format(t)
+		var ok bool
+		if depth, ok = depthFormatOf(f); !ok {
+			return 0, 0, fmt.Errorf("Framebuffer: depth attachment has "+
+				"format %s, not a depth format", enumName(formatNames, f))
+		}
+	}
 
-	s := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
-	if s != gl.FRAMEBUFFER_COMPLETE {
-		log.Panicf("Incomplete framebuffer, reason: %x (level %d)", s, b.Level)
+	if b.ColorFormat != 0 {
+		return depth, b.ColorFormat, nil
+	}
+	// All color renderbuffers share one format.
+	for i, t := range b.colors() {
+		f := colorRenderbufferFormat(format(t))
+		if i > 0 && f != color {
+			return 0, 0, fmt.Errorf("Framebuffer: multisampled color "+
+				"attachments have formats %s and %s",
+				enumName(formatNames, color), enumName(formatNames, f))
+		}
+		color = f
+	}
+	return depth, color, nil
+}
+
+// colorRenderbufferFormat returns the sized renderbuffer format for a color
+// texture of the given internal format.
+func colorRenderbufferFormat(internalformat gl.GLenum) gl.GLenum {
+	switch internalformat {
+	case gl.RGB:
+		return gl.RGB8
+	case gl.RGBA:
+		return gl.RGBA8
+	}
+	return internalformat
+}
+
+// attach sets the attachments of f, which is bound to target.
+func (b *Framebuffer) attach(target gl.GLenum, f *fborbo) {
+	colors := b.colors()
+
+	// The framebuffer is reused by all users with the same dimensions,
+	// so every attachment point is set, clearing those left by others.
+	for i, t := range colors {
+		b.attachTexture(target, gl.COLOR_ATTACHMENT0+gl.GLenum(i), t)
+	}
+	for i := len(colors); i < f.colors; i++ {
+		gl.FramebufferTexture2D(target,
+			gl.COLOR_ATTACHMENT0+gl.GLenum(i), gl.TEXTURE_2D, 0, 0)
+	}
+	f.colors = len(colors)
+
+	gl.FramebufferTexture2D(target, gl.STENCIL_ATTACHMENT,
+		gl.TEXTURE_2D, 0, 0)
+	switch {
+	case b.DepthStencil != nil:
+		b.attachTexture(target, gl.DEPTH_STENCIL_ATTACHMENT, b.DepthStencil)
+	case b.Depth != nil:
+		b.attachTexture(target, gl.DEPTH_ATTACHMENT, b.Depth)
+	case f.rbo != 0:
+		f.rbo.FramebufferRenderbuffer(target,
+			b.DepthFormat.attachment(), gl.RENDERBUFFER)
+	default:
+		gl.FramebufferTexture2D(target, gl.DEPTH_ATTACHMENT,
+			gl.TEXTURE_2D, 0, 0)
+	}
+
+	setDrawBuffers(target, len(colors))
+}
+
+// attachTexture attaches the Target face or Layer of t.
+func (b *Framebuffer) attachTexture(target, attachment gl.GLenum, t *Texture) {
+	switch b.Target {
+	case 0:
+		gl.FramebufferTexture2D(target, attachment, gl.TEXTURE_2D,
+			t.Texture, b.Level)
+	case gl.TEXTURE_2D_ARRAY, gl.TEXTURE_3D, gl.TEXTURE_CUBE_MAP_ARRAY:
+		gl.FramebufferTextureLayer(target, attachment, t.Texture,
+			b.Level, b.Layer)
+	default:
+		gl.FramebufferTexture2D(target, attachment, b.Target,
+			t.Texture, b.Level)
+	}
+}
+
+// drawBuffers returns the first n color attachments, the draw buffers of a
+// framebuffer with n color attachments.
+func drawBuffers(n int) []gl.GLenum {
+	buffers := make([]gl.GLenum, n)
+	for i := range buffers {
+		buffers[i] = gl.COLOR_ATTACHMENT0 + gl.GLenum(i)
+	}
+	return buffers
+}
+
+// setDrawBuffers selects the first n color attachments of the framebuffer
+// bound to target for drawing, and the first for reading.
+func setDrawBuffers(target gl.GLenum, n int) {
+	if target != gl.READ_FRAMEBUFFER {
+		if n == 0 {
+			// Depth only, such as a shadow map.
+			gl.DrawBuffer(gl.NONE)
+		} else {
+			gl.DrawBuffers(n, drawBuffers(n))
+		}
+	}
+	if target != gl.DRAW_FRAMEBUFFER {
+		if n == 0 {
+			gl.ReadBuffer(gl.NONE)
+		} else {
+			gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+		}
 	}
 }
 
 func (b *Framebuffer) Exit() {
-	b.fbo.Unbind()
+	Viewport{}.Exit()
+	if b.ms != nil {
+		b.resolve()
+	}
+	b.unbind()
 }
 
+func (b *Framebuffer) pool() *FramebufferPool {
+	if b.Pool != nil {
+		return b.Pool
+	}
+	return DefaultFramebufferPool
+}
+
+// resolve blits the multisampled renderbuffers into the textures.
+func (b *Framebuffer) resolve() {
+	w, h := levelSize(b.size(), b.Level)
+	b.ms.fbo.BindTarget(gl.READ_FRAMEBUFFER)
+	b.fbo.BindTarget(gl.DRAW_FRAMEBUFFER)
+
+	// Color attachments are resolved one at a time, as a blit writes the
+	// read buffer to every draw buffer.
+	n := len(b.colors())
+	for i := 0; i < n; i++ {
+		attachment := gl.COLOR_ATTACHMENT0 + gl.GLenum(i)
+		gl.ReadBuffer(attachment)
+		gl.DrawBuffers(1, []gl.GLenum{attachment})
+		gl.BlitFramebuffer(0, 0, w, h, 0, 0, w, h,
+			gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	}
+
+	switch {
+	case b.DepthStencil != nil:
+		gl.BlitFramebuffer(0, 0, w, h, 0, 0, w, h,
+			gl.DEPTH_BUFFER_BIT|gl.STENCIL_BUFFER_BIT, gl.NEAREST)
+	case b.Depth != nil:
+		gl.BlitFramebuffer(0, 0, w, h, 0, 0, w, h,
+			gl.DEPTH_BUFFER_BIT, gl.NEAREST)
+	}
+
+	b.fbo.Bind()
+	setDrawBuffers(gl.FRAMEBUFFER, n)
+}
+
+// levelSize returns the dimensions of the given mipmap level of t.
+func levelSize(t *Texture, level int) (w, h int) {
+	w, h = t.W>>uint(level), t.H>>uint(level)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// Returns the maximum number of samples supported for multisampled
+// framebuffers.
+func MaxSamples() int {
+	var n [1]int32
+	gl.GetIntegerv(gl.MAX_SAMPLES, n[:])
+	return int(n[0])
+}
+
+// BindFramebuffer binds the framebuffer to target, which is
+// gl.READ_FRAMEBUFFER or gl.DRAW_FRAMEBUFFER. Inside the context this is the
+// framebuffer object in use. Otherwise the attachments are attached to a
+// framebuffer object reserved for the target, so a Framebuffer can be read
+// from while another is drawn to, even when they have the same dimensions.
 func (b *Framebuffer) BindFramebuffer(target gl.GLenum) {
-	b.fborbo.fbo.BindTarget(target)
+	if b.fborbo != nil {
+		b.fbo.BindTarget(target)
+		return
+	}
+	if err := b.checkAttachments(); err != nil {
+		log.Panic(err)
+	}
+
+	i := 0
+	if target == gl.DRAW_FRAMEBUFFER {
+		i = 1
+	}
+	f := blitFramebuffers[i]
+	if f == nil {
+		f = &fborbo{fbo: gl.GenFramebuffer()}
+		OpenGLSentinel()
+		blitFramebuffers[i] = f
+	}
+	f.fbo.BindTarget(target)
+	b.attach(target, f)
+	if err := b.checkStatus(target); err != nil {
+		panic(err)
+	}
 }
+
+// Framebuffer objects without renderbuffers to which Framebuffers are
+// attached by BindFramebuffer outside their context, for reading and
+// drawing respectively.
+var blitFramebuffers [2]*fborbo
@@ -0,0 +1,196 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+func TestFramebufferErrorString(t *testing.T) {
+	err := &FramebufferError{
+		Status: gl.FRAMEBUFFER_INCOMPLETE_MULTISAMPLE,
+		Level:  1,
+		Attachments: []FramebufferAttachment{
+			{gl.COLOR_ATTACHMENT0 + 1, false, gl.RGBA16F, 128, 64, 0},
+			{gl.DEPTH_STENCIL_ATTACHMENT, true, gl.DEPTH24_STENCIL8, 128, 64, 4},
+			{gl.DEPTH_ATTACHMENT, true, 0x1234, 1, 1, 0},
+		},
+	}
+	want := "Incomplete framebuffer: INCOMPLETE_MULTISAMPLE (level 1)" +
+		"\n\tCOLOR_ATTACHMENT1: texture RGBA16F 128x64" +
+		"\n\tDEPTH_STENCIL_ATTACHMENT: renderbuffer DEPTH24_STENCIL8 128x64, 4 samples" +
+		"\n\tDEPTH_ATTACHMENT: renderbuffer 0x1234 1x1"
+	if s := err.Error(); s != want {
+		t.Errorf("got:\n%s\nwant:\n%s", s, want)
+	}
+}
+
+func TestFramebufferCheckAttachments(t *testing.T) {
+	a, b := &Texture{W: 64, H: 64}, &Texture{W: 32, H: 64}
+	tests := []struct {
+		Framebuffer *Framebuffer
+		OK          bool
+	}{
+		{&Framebuffer{Texture: a}, true},
+		{&Framebuffer{Colors: []*Texture{a, a}, Depth: a}, true},
+		{&Framebuffer{Depth: a}, true},
+		{&Framebuffer{}, false},
+		{&Framebuffer{Texture: a, Colors: []*Texture{b}}, false},
+		{&Framebuffer{Colors: []*Texture{a, b}}, false},
+		{&Framebuffer{Colors: []*Texture{a, nil}}, false},
+		{&Framebuffer{Texture: a, Depth: b}, false},
+		{&Framebuffer{Texture: a, Depth: a, DepthStencil: a}, false},
+	}
+	for i, test := range tests {
+		err := test.Framebuffer.checkAttachments()
+		if (err == nil) != test.OK {
+			t.Errorf("%d: checkAttachments() = %v", i, err)
+		}
+	}
+}
+
+func TestFramebufferMultisampleFormats(t *testing.T) {
+	rgba := &Texture{gl.Texture(1), 8, 8}
+	rgba16f := &Texture{gl.Texture(2), 8, 8}
+	rgba8 := &Texture{gl.Texture(3), 8, 8}
+	depth16 := &Texture{gl.Texture(4), 8, 8}
+	depth32f := &Texture{gl.Texture(5), 8, 8}
+	depth32fs8 := &Texture{gl.Texture(6), 8, 8}
+	color := &Texture{gl.Texture(7), 8, 8}
+
+	formats := map[*Texture]gl.GLenum{
+		rgba:       gl.RGBA,
+		rgba16f:    gl.RGBA16F,
+		rgba8:      gl.RGBA8,
+		depth16:    gl.DEPTH_COMPONENT16,
+		depth32f:   gl.DEPTH_COMPONENT32F,
+		depth32fs8: gl.DEPTH32F_STENCIL8,
+		color:      gl.RGBA8,
+	}
+	format := func(t *Texture) gl.GLenum { return formats[t] }
+
+	tests := []struct {
+		Framebuffer *Framebuffer
+		Depth       DepthFormat
+		Color       gl.GLenum
+		OK          bool
+	}{
+		// Renderbuffer depth, colors from the texture.
+		{&Framebuffer{Texture: rgba16f}, Depth24, gl.RGBA16F, true},
+		{&Framebuffer{Texture: rgba}, Depth24, gl.RGBA8, true},
+		{&Framebuffer{Texture: rgba, DepthFormat: Depth16}, Depth16, gl.RGBA8, true},
+		{&Framebuffer{Texture: rgba, DepthFormat: DepthNone}, DepthNone, gl.RGBA8, true},
+		// Depth textures of any precision.
+		{&Framebuffer{Texture: rgba8, Depth: depth16}, Depth16, gl.RGBA8, true},
+		{&Framebuffer{Texture: rgba8, Depth: depth32f}, Depth32F, gl.RGBA8, true},
+		{&Framebuffer{Depth: depth32f}, Depth32F, 0, true},
+		{&Framebuffer{Texture: rgba8, DepthStencil: depth32fs8}, Depth32FStencil8, gl.RGBA8, true},
+		// An explicit ColorFormat wins.
+		{&Framebuffer{Texture: rgba8, ColorFormat: gl.SRGB8_ALPHA8}, Depth24, gl.SRGB8_ALPHA8, true},
+		// Colors must share one format, and depth must be a depth format.
+		{&Framebuffer{Colors: []*Texture{rgba8, color}}, Depth24, gl.RGBA8, true},
+		{&Framebuffer{Colors: []*Texture{rgba8, rgba16f}}, 0, 0, false},
+		{&Framebuffer{Texture: rgba8, Depth: rgba16f}, 0, 0, false},
+	}
+
+	for i, tt := range tests {
+		depth, color, err := tt.Framebuffer.msFormats(format)
+		if (err == nil) != tt.OK {
+			t.Errorf("%d: msFormats() error %v", i, err)
+			continue
+		}
+		if err == nil && (depth != tt.Depth || color != tt.Color) {
+			t.Errorf("%d: Want %d, %s, Have %d, %s", i, tt.Depth,
+				enumName(formatNames, tt.Color), depth, enumName(formatNames, color))
+		}
+	}
+}
+
+// The textures in Colors are drawn to in order, through consecutive color
+// attachments.
+func TestFramebufferDrawBuffers(t *testing.T) {
+	a := &Texture{W: 8, H: 8}
+	tests := []struct {
+		Framebuffer *Framebuffer
+		Out         []gl.GLenum
+	}{
+		{&Framebuffer{Depth: a}, []gl.GLenum{}},
+		{&Framebuffer{Texture: a}, []gl.GLenum{gl.COLOR_ATTACHMENT0}},
+		{&Framebuffer{Texture: a, Colors: []*Texture{a, a, a}},
+			[]gl.GLenum{gl.COLOR_ATTACHMENT0, gl.COLOR_ATTACHMENT0 + 1,
+				gl.COLOR_ATTACHMENT0 + 2}},
+	}
+	for i, tt := range tests {
+		out := drawBuffers(len(tt.Framebuffer.colors()))
+		if !reflect.DeepEqual(out, tt.Out) {
+			t.Errorf("%d: Want %v, Have %v", i, tt.Out, out)
+		}
+	}
+}
+
+func TestLevelSize(t *testing.T) {
+	tests := []struct {
+		W, H, Level int
+		OutW, OutH  int
+	}{
+		{64, 64, 0, 64, 64},
+		{64, 64, 3, 8, 8},
+		{64, 64, 6, 1, 1},
+		{64, 64, 8, 1, 1},
+		{256, 32, 0, 256, 32},
+		{256, 32, 2, 64, 8},
+		{256, 32, 6, 4, 1},
+		{31, 100, 1, 15, 50},
+		{31, 100, 5, 1, 3},
+	}
+	for _, tt := range tests {
+		w, h := levelSize(&Texture{W: tt.W, H: tt.H}, tt.Level)
+		if w != tt.OutW || h != tt.OutH {
+			t.Errorf("%dx%d level %d: Want %dx%d, Have %dx%d",
+				tt.W, tt.H, tt.Level, tt.OutW, tt.OutH, w, h)
+		}
+	}
+}
+
+// Entering a Framebuffer sets the viewport to its mipmap level, taken from
+// the depth texture if there is no color texture, and Exit restores it.
+func TestFramebufferViewport(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		color := NewTexture(64, 32)
+		color.InitFormat(gl.RGBA8)
+		defer color.Delete()
+		depth := NewDepthTexture(16, 16)
+		defer depth.Delete()
+
+		viewport := func() (v [4]int32) {
+			gl.GetIntegerv(gl.VIEWPORT, v[:])
+			return v
+		}
+		gl.Viewport(1, 2, 3, 4)
+
+		tests := []struct {
+			Framebuffer *Framebuffer
+			Out         [4]int32
+		}{
+			{&Framebuffer{Texture: color}, [4]int32{0, 0, 64, 32}},
+			{&Framebuffer{Texture: color, Level: 2}, [4]int32{0, 0, 16, 8}},
+			{&Framebuffer{Depth: depth}, [4]int32{0, 0, 16, 16}},
+		}
+		for i, tt := range tests {
+			With(tt.Framebuffer, func() {
+				if v := viewport(); v != tt.Out {
+					t.Errorf("%d: Want %v, Have %v", i, tt.Out, v)
+				}
+			})
+			if v := viewport(); v != [4]int32{1, 2, 3, 4} {
+				t.Errorf("%d: viewport not restored: %v", i, v)
+			}
+		}
+	}, func() {})
+}
@@ -0,0 +1,189 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-gl/gl"
+)
+
+// A FramebufferError reports an incomplete framebuffer, with a description
+// of its attachments. Framebuffer.Enter panics with it, and
+// Framebuffer.Validate returns it.
+type FramebufferError struct {
+	Status      gl.GLenum // Result of glCheckFramebufferStatus.
+	Level       int
+	Attachments []FramebufferAttachment
+}
+
+// A FramebufferAttachment describes an image attached to a framebuffer.
+type FramebufferAttachment struct {
+	Attachment   gl.GLenum // Such as gl.COLOR_ATTACHMENT0.
+	Renderbuffer bool      // Otherwise a texture.
+	Format       gl.GLenum // Internal format.
+	W, H         int
+	Samples      int
+}
+
+var framebufferStatusNames = map[gl.GLenum]string{
+	gl.FRAMEBUFFER_UNDEFINED:                     "UNDEFINED",
+	gl.FRAMEBUFFER_INCOMPLETE_ATTACHMENT:         "INCOMPLETE_ATTACHMENT",
+	gl.FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT: "INCOMPLETE_MISSING_ATTACHMENT",
+	gl.FRAMEBUFFER_INCOMPLETE_DRAW_BUFFER:        "INCOMPLETE_DRAW_BUFFER",
+	gl.FRAMEBUFFER_INCOMPLETE_READ_BUFFER:        "INCOMPLETE_READ_BUFFER",
+	gl.FRAMEBUFFER_UNSUPPORTED:                   "UNSUPPORTED",
+	gl.FRAMEBUFFER_INCOMPLETE_MULTISAMPLE:        "INCOMPLETE_MULTISAMPLE",
+	gl.FRAMEBUFFER_INCOMPLETE_LAYER_TARGETS:      "INCOMPLETE_LAYER_TARGETS",
+}
+
+var formatNames = map[gl.GLenum]string{
+	gl.RGB:                "RGB",
+	gl.RGBA:               "RGBA",
+	gl.R8:                 "R8",
+	gl.RG8:                "RG8",
+	gl.RGB8:               "RGB8",
+	gl.RGBA8:              "RGBA8",
+	gl.SRGB8_ALPHA8:       "SRGB8_ALPHA8",
+	gl.RGB10_A2:           "RGB10_A2",
+	gl.R16F:               "R16F",
+	gl.R32F:               "R32F",
+	gl.RGB16F:             "RGB16F",
+	gl.RGBA16F:            "RGBA16F",
+	gl.RGBA32F:            "RGBA32F",
+	gl.R11F_G11F_B10F:     "R11F_G11F_B10F",
+	gl.DEPTH_COMPONENT:    "DEPTH_COMPONENT",
+	gl.DEPTH_COMPONENT16:  "DEPTH_COMPONENT16",
+	gl.DEPTH_COMPONENT24:  "DEPTH_COMPONENT24",
+	gl.DEPTH_COMPONENT32:  "DEPTH_COMPONENT32",
+	gl.DEPTH_COMPONENT32F: "DEPTH_COMPONENT32F",
+	gl.DEPTH_STENCIL:      "DEPTH_STENCIL",
+	gl.DEPTH24_STENCIL8:   "DEPTH24_STENCIL8",
+	gl.DEPTH32F_STENCIL8:  "DEPTH32F_STENCIL8",
+}
+
+func enumName(names map[gl.GLenum]string, e gl.GLenum) string {
+	if name, ok := names[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", uint32(e))
+}
+
+func attachmentName(a gl.GLenum) string {
+	switch a {
+	case gl.DEPTH_ATTACHMENT:
+		return "DEPTH_ATTACHMENT"
+	case gl.STENCIL_ATTACHMENT:
+		return "STENCIL_ATTACHMENT"
+	case gl.DEPTH_STENCIL_ATTACHMENT:
+		return "DEPTH_STENCIL_ATTACHMENT"
+	}
+	if a >= gl.COLOR_ATTACHMENT0 && a < gl.COLOR_ATTACHMENT0+32 {
+		return fmt.Sprintf("COLOR_ATTACHMENT%d", a-gl.COLOR_ATTACHMENT0)
+	}
+	return fmt.Sprintf("0x%x", uint32(a))
+}
+
+func (a FramebufferAttachment) String() string {
+	kind := "texture"
+	if a.Renderbuffer {
+		kind = "renderbuffer"
+	}
+	s := fmt.Sprintf("%s: %s %s %dx%d", attachmentName(a.Attachment), kind,
+		enumName(formatNames, a.Format), a.W, a.H)
+	if a.Samples > 0 {
+		s += fmt.Sprintf(", %d samples", a.Samples)
+	}
+	return s
+}
+
+func (e *FramebufferError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Incomplete framebuffer: %s (level %d)",
+		enumName(framebufferStatusNames, e.Status), e.Level)
+	if len(e.Attachments) == 0 {
+		buf.WriteString(", no attachments")
+	}
+	for _, a := range e.Attachments {
+		fmt.Fprintf(&buf, "\n\t%v", a)
+	}
+	return buf.String()
+}
+
+// checkStatus returns a *FramebufferError if the framebuffer bound to
+// target is incomplete.
+func (b *Framebuffer) checkStatus(target gl.GLenum) error {
+	s := gl.CheckFramebufferStatus(target)
+	if s == gl.FRAMEBUFFER_COMPLETE {
+		return nil
+	}
+	return &FramebufferError{Status: s, Level: b.Level, Attachments: b.describe()}
+}
+
+// describe returns the attachments of the framebuffer last bound, the
+// multisampled one if present.
+func (b *Framebuffer) describe() []FramebufferAttachment {
+	var result []FramebufferAttachment
+	w, h := levelSize(b.size(), b.Level)
+
+	if b.ms != nil {
+		key := b.ms.key
+		for i := 0; i < key.colors; i++ {
+			result = append(result, FramebufferAttachment{
+				gl.COLOR_ATTACHMENT0 + gl.GLenum(i), true, key.color,
+				w, h, key.samples})
+		}
+		if key.depth != DepthNone {
+			result = append(result, FramebufferAttachment{
+				key.depth.attachment(), true, key.depth.internalFormat(),
+				w, h, key.samples})
+		}
+		return result
+	}
+
+	texture := func(a gl.GLenum, t *Texture) FramebufferAttachment {
+		return FramebufferAttachment{a, false,
+			textureFormat(t, b.Target, b.Level), w, h, 0}
+	}
+	for i, t := range b.colors() {
+		result = append(result, texture(gl.COLOR_ATTACHMENT0+gl.GLenum(i), t))
+	}
+	switch {
+	case b.DepthStencil != nil:
+		result = append(result,
+			texture(gl.DEPTH_STENCIL_ATTACHMENT, b.DepthStencil))
+	case b.Depth != nil:
+		result = append(result, texture(gl.DEPTH_ATTACHMENT, b.Depth))
+	case b.fborbo != nil && b.rbo != 0:
+		result = append(result, FramebufferAttachment{
+			b.DepthFormat.attachment(), true, b.DepthFormat.internalFormat(),
+			b.size().W, b.size().H, 0})
+	}
+	return result
+}
+
+// textureFormat returns the internal format of level of t, for a
+// Framebuffer.Target of target. The texture binding is restored.
+func textureFormat(t *Texture, target gl.GLenum, level int) gl.GLenum {
+	bind := target
+	switch target {
+	case 0:
+		bind, target = gl.TEXTURE_2D, gl.TEXTURE_2D
+	case gl.TEXTURE_CUBE_MAP_POSITIVE_X, gl.TEXTURE_CUBE_MAP_NEGATIVE_X,
+		gl.TEXTURE_CUBE_MAP_POSITIVE_Y, gl.TEXTURE_CUBE_MAP_NEGATIVE_Y,
+		gl.TEXTURE_CUBE_MAP_POSITIVE_Z, gl.TEXTURE_CUBE_MAP_NEGATIVE_Z:
+		bind = gl.TEXTURE_CUBE_MAP
+	}
+
+	prev := gl.Texture(getBinding(textureBindingFor(bind)))
+	defer prev.Bind(bind)
+
+	var format [1]int32
+	t.Bind(bind)
+	gl.GetTexLevelParameteriv(target, level, gl.TEXTURE_INTERNAL_FORMAT,
+		format[:])
+	return gl.GLenum(format[0])
+}
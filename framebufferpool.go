@@ -0,0 +1,133 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"container/list"
+)
+
+// A FramebufferPool shares framebuffer objects, and their renderbuffers,
+// between Framebuffer contexts. A Framebuffer uses its framebuffer object
+// exclusively from Enter to Exit, so nested Framebuffers of the same
+// dimensions and formats get distinct ones. Idle framebuffer objects are
+// kept for reuse, and the least recently used are deleted when the pool holds
+// more than Capacity. Those in use are never deleted, so the pool may exceed
+// its capacity while more are in use.
+//
+// Some graphics cards run out of memory with O(1000) framebuffers, so the
+// capacity should be well below that.
+type FramebufferPool struct {
+	Capacity int
+
+	entries map[fbKey][]*fborbo // Idle and in use entries.
+	live    int                 // Number of entries.
+	lru     *list.List          // Idle entries, most recently used first.
+	stats   FramebufferPoolStats
+
+	// Create and delete the framebuffer objects. Replaceable for testing.
+	create func(fbKey) *fborbo
+	delete func(*fborbo)
+}
+
+// FramebufferPoolStats counts the framebuffer objects in a pool, and how
+// often they were reused.
+type FramebufferPoolStats struct {
+	Live      int // Framebuffer objects in the pool.
+	InUse     int // Framebuffer objects used by an entered Framebuffer.
+	Hits      int // Acquisitions which reused a framebuffer object.
+	Misses    int // Acquisitions which created a framebuffer object.
+	Evictions int // Framebuffer objects deleted to respect the capacity.
+}
+
+// The pool used by Framebuffer contexts which do not specify one.
+var DefaultFramebufferPool = NewFramebufferPool(64)
+
+// NewFramebufferPool creates a pool which keeps at most capacity
+// idle framebuffer objects.
+func NewFramebufferPool(capacity int) *FramebufferPool {
+	return &FramebufferPool{
+		Capacity: capacity,
+		entries:  make(map[fbKey][]*fborbo),
+		lru:      list.New(),
+		create:   newFBORBO,
+		delete:   deleteFBORBO,
+	}
+}
+
+// acquire returns an idle framebuffer object for key, creating one if
+// there is none, and marks it in use.
+func (p *FramebufferPool) acquire(key fbKey) *fborbo {
+	p.stats.InUse++
+
+	for _, f := range p.entries[key] {
+		if f.elem != nil {
+			p.stats.Hits++
+			p.lru.Remove(f.elem)
+			f.elem = nil
+			return f
+		}
+	}
+
+	p.stats.Misses++
+	p.evict(p.Capacity - 1)
+	f := p.create(key)
+	f.key = key
+	p.entries[key] = append(p.entries[key], f)
+	p.live++
+	return f
+}
+
+// release returns a framebuffer object obtained from acquire to the pool.
+func (p *FramebufferPool) release(f *fborbo) {
+	if f.elem != nil {
+		panic("glh: framebuffer released more often than acquired")
+	}
+	p.stats.InUse--
+	f.elem = p.lru.PushFront(f)
+	p.evict(p.Capacity)
+}
+
+// evict deletes the least recently used idle framebuffer objects until the
+// pool holds at most n, or none are idle.
+func (p *FramebufferPool) evict(n int) {
+	for p.live > n && p.lru.Len() > 0 {
+		p.remove(p.lru.Back().Value.(*fborbo))
+		p.stats.Evictions++
+	}
+}
+
+func (p *FramebufferPool) remove(f *fborbo) {
+	p.lru.Remove(f.elem)
+	f.elem = nil
+
+	list := p.entries[f.key]
+	for i, g := range list {
+		if g == f {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(p.entries, f.key)
+	} else {
+		p.entries[f.key] = list
+	}
+	p.live--
+	p.delete(f)
+}
+
+// Release deletes all idle framebuffer objects.
+func (p *FramebufferPool) Release() {
+	for p.lru.Len() > 0 {
+		p.remove(p.lru.Back().Value.(*fborbo))
+	}
+}
+
+// Stats returns the current statistics of the pool.
+func (p *FramebufferPool) Stats() FramebufferPoolStats {
+	s := p.stats
+	s.Live = p.live
+	return s
+}
@@ -0,0 +1,96 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"image"
+	"testing"
+)
+
+// testPool returns a pool which records deletions instead of calling OpenGL.
+func testPool(capacity int) (*FramebufferPool, *[]fbKey) {
+	var deleted []fbKey
+	p := NewFramebufferPool(capacity)
+	p.create = func(key fbKey) *fborbo { return &fborbo{} }
+	p.delete = func(f *fborbo) { deleted = append(deleted, f.key) }
+	return p, &deleted
+}
+
+func sizeKey(w, h int) fbKey { return fbKey{size: image.Point{w, h}} }
+
+func TestFramebufferPoolReuse(t *testing.T) {
+	p, deleted := testPool(4)
+
+	a := p.acquire(sizeKey(64, 64))
+	p.release(a)
+	b := p.acquire(sizeKey(64, 64))
+	if a != b {
+		t.Fatal("framebuffer of equal key not reused")
+	}
+	c := p.acquire(sizeKey(64, 64))
+	if b == c {
+		t.Fatal("framebuffer in use handed out again")
+	}
+	p.release(c)
+	p.release(b)
+	d := p.acquire(sizeKey(64, 64))
+	if d != b && d != c {
+		t.Fatal("idle framebuffer not reused")
+	}
+	p.release(d)
+
+	s := p.Stats()
+	if s.Live != 2 || s.InUse != 0 || s.Hits != 2 || s.Misses != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if len(*deleted) != 0 {
+		t.Errorf("deleted %v", *deleted)
+	}
+}
+
+func TestFramebufferPoolEviction(t *testing.T) {
+	p, deleted := testPool(2)
+
+	for _, k := range []fbKey{sizeKey(1, 1), sizeKey(2, 2)} {
+		p.release(p.acquire(k))
+	}
+	// Touch 1x1, so that 2x2 is the least recently used.
+	p.release(p.acquire(sizeKey(1, 1)))
+	p.release(p.acquire(sizeKey(3, 3)))
+
+	if len(*deleted) != 1 || (*deleted)[0] != sizeKey(2, 2) {
+		t.Fatalf("deleted %v, want [2x2]", *deleted)
+	}
+	if s := p.Stats(); s.Live != 2 || s.Evictions != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestFramebufferPoolInUseNotEvicted(t *testing.T) {
+	p, deleted := testPool(1)
+
+	a := p.acquire(sizeKey(1, 1))
+	b := p.acquire(sizeKey(2, 2))
+	if len(*deleted) != 0 {
+		t.Fatalf("deleted in use framebuffers %v", *deleted)
+	}
+	if s := p.Stats(); s.Live != 2 || s.InUse != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	p.release(a)
+	if len(*deleted) != 1 || (*deleted)[0] != sizeKey(1, 1) {
+		t.Fatalf("deleted %v, want [1x1]", *deleted)
+	}
+	p.release(b)
+	if len(*deleted) != 1 {
+		t.Fatalf("deleted %v, want only [1x1]", *deleted)
+	}
+
+	p.Release()
+	if len(*deleted) != 2 || p.Stats().Live != 0 {
+		t.Errorf("Release left %+v, deleted %v", p.Stats(), *deleted)
+	}
+}
@@ -0,0 +1,463 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// A FrameGraph schedules render passes declaratively. Each pass names the
+// textures it reads and writes; the graph orders the passes so that every
+// texture is written before it is read, culls passes whose results are never
+// used and allocates the transient textures, sharing one texture between
+// resources whose lifetimes do not overlap.
+//
+// Example:
+//     g := NewFrameGraph()
+//     g.Texture("scene", w, h)
+//     g.Texture("blurred", w, h)
+//     g.AddPass("scene", nil, []string{"scene"}, drawScene)
+//     g.AddPass("blur", []string{"scene"}, []string{"blurred"}, blur)
+//     g.AddPass("present", []string{"blurred"}, nil, present)
+//     if err := g.Compile(); err != nil { .. }
+//     g.Execute() // Each frame
+//
+// A pass which writes no textures draws to the current framebuffer, usually
+// the window, and is never culled. Other passes are kept only if their
+// results are read by a kept pass or marked with Output.
+type FrameGraph struct {
+	resources map[string]*fgResource
+	names     []string // Resource names in declaration order.
+	passes    []*fgPass
+	compiled  bool
+
+	order    []*fgPass    // Kept passes in execution order.
+	physical []*fgTexture // Textures backing the transient resources.
+}
+
+// A PassFunc renders a pass. Inputs maps the names of the textures the pass
+// reads to their textures. It is called with the pass's output textures bound
+// as the framebuffer's color attachments, in the order they were listed.
+type PassFunc func(inputs map[string]*Texture)
+
+type fgResource struct {
+	name     string
+	w, h     int
+	imported *Texture
+	output   bool
+
+	writer  *fgPass
+	readers []*fgPass
+
+	physical int // Index into FrameGraph.physical, -1 if not allocated.
+}
+
+type fgPass struct {
+	name   string
+	reads  []string
+	writes []string
+	fn     PassFunc
+	index  int  // Declaration order.
+	kept   bool // Not culled.
+}
+
+type fgTexture struct {
+	w, h    int
+	texture *Texture
+}
+
+// NewFrameGraph creates an empty frame graph.
+func NewFrameGraph() *FrameGraph {
+	return &FrameGraph{resources: make(map[string]*fgResource)}
+}
+
+func (g *FrameGraph) declare(name string) *fgResource {
+	if _, ok := g.resources[name]; ok {
+		panic("FrameGraph: resource declared twice: " + name)
+	}
+	r := &fgResource{name: name, physical: -1}
+	g.resources[name] = r
+	g.names = append(g.names, name)
+	g.compiled = false
+	return r
+}
+
+// Texture declares a transient texture of the given size, allocated by the
+// graph.
+func (g *FrameGraph) Texture(name string, w, h int) {
+	r := g.declare(name)
+	r.w, r.h = w, h
+}
+
+// Import declares a texture owned by the caller. Imported textures can be
+// read without a pass writing them, and are never aliased.
+func (g *FrameGraph) Import(name string, t *Texture) {
+	r := g.declare(name)
+	r.w, r.h, r.imported = t.W, t.H, t
+}
+
+// Output marks a resource as a result of the graph, so the passes producing
+// it are never culled and its texture is not reused by later passes.
+func (g *FrameGraph) Output(name string) {
+	g.resource(name).output = true
+	g.compiled = false
+}
+
+// Result returns the texture backing the named resource. For transient
+// resources this is only valid after Execute.
+func (g *FrameGraph) Result(name string) *Texture {
+	r := g.resource(name)
+	if r.imported != nil {
+		return r.imported
+	}
+	if r.physical < 0 {
+		return nil
+	}
+	return g.physical[r.physical].texture
+}
+
+func (g *FrameGraph) resource(name string) *fgResource {
+	r, ok := g.resources[name]
+	if !ok {
+		panic("FrameGraph: unknown resource: " + name)
+	}
+	return r
+}
+
+// AddPass adds a pass reading and writing the named resources.
+func (g *FrameGraph) AddPass(name string, reads, writes []string, fn PassFunc) {
+	g.passes = append(g.passes, &fgPass{
+		name:   name,
+		reads:  reads,
+		writes: writes,
+		fn:     fn,
+		index:  len(g.passes),
+	})
+	g.compiled = false
+}
+
+// Compile orders and culls the passes and assigns textures to the transient
+// resources. It returns an error if a resource is undeclared, written twice
+// or read without being written, if the resources a pass writes differ in
+// size, or if the passes form a cycle.
+//
+// Compile does not call OpenGL; textures are created by the first Execute.
+func (g *FrameGraph) Compile() error {
+	for _, r := range g.resources {
+		r.writer, r.readers, r.physical = nil, nil, -1
+	}
+
+	for _, p := range g.passes {
+		for _, name := range p.writes {
+			r, ok := g.resources[name]
+			if !ok {
+				return fmt.Errorf("FrameGraph: pass %q writes undeclared "+
+					"resource %q", p.name, name)
+			}
+			if r.imported != nil {
+				return fmt.Errorf("FrameGraph: pass %q writes imported "+
+					"resource %q", p.name, name)
+			}
+			if r.writer != nil {
+				return fmt.Errorf("FrameGraph: resource %q written by "+
+					"passes %q and %q", name, r.writer.name, p.name)
+			}
+			r.writer = p
+
+			// The writes are attached to one framebuffer.
+			if first := g.resources[p.writes[0]]; r.w != first.w || r.h != first.h {
+				return fmt.Errorf("FrameGraph: pass %q writes resource %q "+
+					"of %dx%d and %q of %dx%d", p.name, first.name,
+					first.w, first.h, name, r.w, r.h)
+			}
+		}
+	}
+
+	for _, p := range g.passes {
+		for _, name := range p.reads {
+			r, ok := g.resources[name]
+			if !ok {
+				return fmt.Errorf("FrameGraph: pass %q reads undeclared "+
+					"resource %q", p.name, name)
+			}
+			if r.writer == nil && r.imported == nil {
+				return fmt.Errorf("FrameGraph: pass %q reads resource %q "+
+					"which no pass writes", p.name, name)
+			}
+			r.readers = append(r.readers, p)
+		}
+	}
+
+	g.cull()
+
+	order, err := g.sort()
+	if err != nil {
+		return err
+	}
+	g.order = order
+
+	g.allocate()
+	g.compiled = true
+	return nil
+}
+
+// cull marks the passes which contribute to the window or an output.
+func (g *FrameGraph) cull() {
+	var work []*fgPass
+	for _, p := range g.passes {
+		p.kept = len(p.writes) == 0
+		for _, name := range p.writes {
+			if g.resources[name].output {
+				p.kept = true
+			}
+		}
+		if p.kept {
+			work = append(work, p)
+		}
+	}
+
+	for len(work) > 0 {
+		p := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for _, name := range p.reads {
+			w := g.resources[name].writer
+			if w != nil && !w.kept {
+				w.kept = true
+				work = append(work, w)
+			}
+		}
+	}
+}
+
+// sort orders the kept passes so that writers precede readers. Passes which
+// do not depend on each other keep their declaration order.
+func (g *FrameGraph) sort() ([]*fgPass, error) {
+	pending := make(map[*fgPass]int) // Number of unscheduled dependencies.
+	var kept []*fgPass
+	for _, p := range g.passes {
+		if !p.kept {
+			continue
+		}
+		kept = append(kept, p)
+		for _, name := range p.reads {
+			if g.resources[name].writer != nil {
+				pending[p]++
+			}
+		}
+	}
+
+	var order, ready []*fgPass
+	for _, p := range kept {
+		if pending[p] == 0 {
+			ready = append(ready, p)
+		}
+	}
+
+	for len(ready) > 0 {
+		sort.Sort(passesByIndex(ready))
+		p := ready[0]
+		ready = ready[1:]
+		order = append(order, p)
+
+		for _, name := range p.writes {
+			for _, reader := range g.resources[name].readers {
+				if !reader.kept {
+					continue
+				}
+				pending[reader]--
+				if pending[reader] == 0 {
+					ready = append(ready, reader)
+				}
+			}
+		}
+	}
+
+	if len(order) != len(kept) {
+		var cycle []string
+		for _, p := range kept {
+			if pending[p] > 0 {
+				cycle = append(cycle, p.name)
+			}
+		}
+		return nil, fmt.Errorf("FrameGraph: cycle between passes %q", cycle)
+	}
+	return order, nil
+}
+
+type passesByIndex []*fgPass
+
+func (p passesByIndex) Len() int           { return len(p) }
+func (p passesByIndex) Less(i, j int) bool { return p[i].index < p[j].index }
+func (p passesByIndex) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// allocate assigns a physical texture to each transient resource written by
+// a kept pass. A texture is reused once the last reader of its previous
+// resource has executed. Existing textures are kept across compiles.
+func (g *FrameGraph) allocate() {
+	// The position in g.order after which each resource is no longer needed.
+	last := make(map[*fgResource]int)
+	for i, p := range g.order {
+		for _, name := range p.writes {
+			last[g.resources[name]] = i
+		}
+		for _, name := range p.reads {
+			last[g.resources[name]] = i
+		}
+	}
+
+	inUse := make([]bool, len(g.physical))
+
+	for i, p := range g.order {
+		for _, name := range p.writes {
+			r := g.resources[name]
+			r.physical = g.acquire(r.w, r.h, &inUse)
+		}
+
+		for _, name := range append(append([]string(nil), p.reads...), p.writes...) {
+			r := g.resources[name]
+			if r.physical >= 0 && !r.output && last[r] == i {
+				inUse[r.physical] = false
+			}
+		}
+	}
+}
+
+// acquire returns the index of a free physical texture of the given size,
+// adding one if necessary.
+func (g *FrameGraph) acquire(w, h int, inUse *[]bool) int {
+	for i, t := range g.physical {
+		if !(*inUse)[i] && t.w == w && t.h == h {
+			(*inUse)[i] = true
+			return i
+		}
+	}
+	g.physical = append(g.physical, &fgTexture{w: w, h: h})
+	*inUse = append(*inUse, true)
+	return len(g.physical) - 1
+}
+
+// Execute runs the kept passes in order, compiling the graph first if it
+// changed. Transient textures are created on first use.
+func (g *FrameGraph) Execute() {
+	if !g.compiled {
+		if err := g.Compile(); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, p := range g.order {
+		inputs := make(map[string]*Texture, len(p.reads))
+		for _, name := range p.reads {
+			inputs[name] = g.texture(g.resources[name])
+		}
+
+		if len(p.writes) == 0 {
+			p.fn(inputs)
+			continue
+		}
+
+		targets := make([]*Texture, len(p.writes))
+		for i, name := range p.writes {
+			targets[i] = g.texture(g.resources[name])
+		}
+		fn := p.fn
+		With(Named(p.name, &Framebuffer{Colors: targets}), func() {
+			fn(inputs)
+		})
+	}
+}
+
+// texture returns the texture backing r, creating it if necessary.
+func (g *FrameGraph) texture(r *fgResource) *Texture {
+	if r.imported != nil {
+		return r.imported
+	}
+	t := g.physical[r.physical]
+	if t.texture == nil {
+		t.texture = NewTexture(t.w, t.h)
+		t.texture.Init()
+	}
+	return t.texture
+}
+
+// Release deletes the textures allocated by the graph.
+func (g *FrameGraph) Release() {
+	for _, t := range g.physical {
+		if t.texture != nil {
+			t.texture.Delete()
+		}
+	}
+	g.physical = nil
+	g.compiled = false
+}
+
+// String returns a textual description of the compiled graph: the passes
+// in execution order, followed by the culled passes and the texture assigned
+// to each resource.
+func (g *FrameGraph) String() string {
+	var buf bytes.Buffer
+
+	for i, p := range g.order {
+		fmt.Fprintf(&buf, "pass %d %q reads %q writes %q\n",
+			i, p.name, p.reads, p.writes)
+	}
+	for _, p := range g.passes {
+		if !p.kept {
+			fmt.Fprintf(&buf, "culled %q\n", p.name)
+		}
+	}
+	for _, name := range g.names {
+		r := g.resources[name]
+		switch {
+		case r.imported != nil:
+			fmt.Fprintf(&buf, "resource %q %dx%d imported\n", name, r.w, r.h)
+		case r.physical >= 0:
+			fmt.Fprintf(&buf, "resource %q %dx%d texture %d\n",
+				name, r.w, r.h, r.physical)
+		default:
+			fmt.Fprintf(&buf, "resource %q %dx%d unused\n", name, r.w, r.h)
+		}
+	}
+	return buf.String()
+}
+
+// Dot returns the graph in Graphviz DOT format. Passes are boxes, resources
+// ellipses; culled passes are dashed. Names are quoted and escaped, so they
+// may contain any characters.
+func (g *FrameGraph) Dot() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("digraph framegraph {\n")
+	for _, p := range g.passes {
+		style := "solid"
+		if !p.kept {
+			style = "dashed"
+		}
+		fmt.Fprintf(&buf, "\t%q [shape=box, style=%s, label=%q];\n",
+			"pass:"+p.name, style, p.name)
+	}
+	for _, name := range g.names {
+		r := g.resources[name]
+		label := fmt.Sprintf("%s\n%dx%d", name, r.w, r.h)
+		if r.physical >= 0 {
+			label += fmt.Sprintf("\ntexture %d", r.physical)
+		}
+		fmt.Fprintf(&buf, "\t%q [shape=ellipse, label=%q];\n",
+			"res:"+name, label)
+	}
+	for _, p := range g.passes {
+		for _, name := range p.reads {
+			fmt.Fprintf(&buf, "\t%q -> %q;\n", "res:"+name, "pass:"+p.name)
+		}
+		for _, name := range p.writes {
+			fmt.Fprintf(&buf, "\t%q -> %q;\n", "pass:"+p.name, "res:"+name)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
@@ -0,0 +1,179 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func passOrder(g *FrameGraph) []string {
+	var names []string
+	for _, p := range g.order {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+func TestFrameGraphOrderAndCull(t *testing.T) {
+	g := NewFrameGraph()
+	g.Texture("scene", 64, 64)
+	g.Texture("bright", 64, 64)
+	g.Texture("blur", 64, 64)
+	g.Texture("debug", 64, 64)
+
+	// Declared out of order on purpose.
+	g.AddPass("present", []string{"scene", "blur"}, nil, nil)
+	g.AddPass("blur", []string{"bright"}, []string{"blur"}, nil)
+	g.AddPass("debug", []string{"scene"}, []string{"debug"}, nil)
+	g.AddPass("threshold", []string{"scene"}, []string{"bright"}, nil)
+	g.AddPass("scene", nil, []string{"scene"}, nil)
+
+	if err := g.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"scene", "threshold", "blur", "present"}
+	if have := passOrder(g); !reflect.DeepEqual(have, want) {
+		t.Fatalf("order: Want %v, Have %v", want, have)
+	}
+
+	if g.resources["debug"].physical != -1 {
+		t.Fatalf("culled pass output was allocated")
+	}
+
+	// Marking the debug texture as an output keeps its pass.
+	g.Output("debug")
+	if err := g.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"scene", "debug", "threshold", "blur", "present"}
+	if have := passOrder(g); !reflect.DeepEqual(have, want) {
+		t.Fatalf("order with output: Want %v, Have %v", want, have)
+	}
+}
+
+func TestFrameGraphAliasing(t *testing.T) {
+	g := NewFrameGraph()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		g.Texture(name, 32, 32)
+	}
+	g.Texture("small", 16, 16)
+
+	g.AddPass("a", nil, []string{"a"}, nil)
+	g.AddPass("b", []string{"a"}, []string{"b"}, nil)
+	g.AddPass("c", []string{"b"}, []string{"c"}, nil)
+	g.AddPass("small", []string{"c"}, []string{"small"}, nil)
+	g.AddPass("d", []string{"small"}, []string{"d"}, nil)
+	g.AddPass("present", []string{"d"}, nil, nil)
+
+	if err := g.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	phys := func(name string) int { return g.resources[name].physical }
+
+	if phys("a") == phys("b") || phys("b") == phys("c") {
+		t.Fatalf("a pass reads and writes the same texture: %s", g)
+	}
+	if phys("a") != phys("c") {
+		t.Fatalf("a and c have disjoint lifetimes but were not aliased: %s", g)
+	}
+	if phys("small") == phys("a") || phys("small") == phys("b") {
+		t.Fatalf("textures of different sizes were aliased: %s", g)
+	}
+	if len(g.physical) != 3 {
+		t.Fatalf("Want 3 physical textures, Have %d: %s", len(g.physical), g)
+	}
+}
+
+func TestFrameGraphErrors(t *testing.T) {
+	tests := [...]struct {
+		Name  string
+		Build func(g *FrameGraph)
+		Err   string
+	}{
+		{"cycle", func(g *FrameGraph) {
+			g.Texture("a", 1, 1)
+			g.Texture("b", 1, 1)
+			g.AddPass("x", []string{"a"}, []string{"b"}, nil)
+			g.AddPass("y", []string{"b"}, []string{"a"}, nil)
+			g.AddPass("present", []string{"a"}, nil, nil)
+		}, "cycle"},
+		{"unwritten", func(g *FrameGraph) {
+			g.Texture("a", 1, 1)
+			g.AddPass("present", []string{"a"}, nil, nil)
+		}, "which no pass writes"},
+		{"written twice", func(g *FrameGraph) {
+			g.Texture("a", 1, 1)
+			g.AddPass("x", nil, []string{"a"}, nil)
+			g.AddPass("y", nil, []string{"a"}, nil)
+		}, "written by"},
+		{"undeclared", func(g *FrameGraph) {
+			g.AddPass("present", []string{"a"}, nil, nil)
+		}, "undeclared"},
+		{"size mismatch", func(g *FrameGraph) {
+			g.Texture("a", 1, 1)
+			g.Texture("b", 2, 1)
+			g.AddPass("x", nil, []string{"a", "b"}, nil)
+		}, "of 1x1 and \"b\" of 2x1"},
+	}
+
+	for _, test := range tests {
+		g := NewFrameGraph()
+		test.Build(g)
+		err := g.Compile()
+		if err == nil || !strings.Contains(err.Error(), test.Err) {
+			t.Fatalf("%s: Want error containing %q, Have %v",
+				test.Name, test.Err, err)
+		}
+	}
+}
+
+// quotedGraph returns a compiled graph whose names need quoting, with one
+// culled pass.
+func quotedGraph(t *testing.T) *FrameGraph {
+	g := NewFrameGraph()
+	g.Texture(`hdr "linear"`, 8, 4)
+	g.Texture("debug", 8, 4)
+	g.AddPass(`scene "main"`, nil, []string{`hdr "linear"`}, nil)
+	g.AddPass("debug", []string{`hdr "linear"`}, []string{"debug"}, nil)
+	g.AddPass("present", []string{`hdr "linear"`}, nil, nil)
+	if err := g.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestFrameGraphString(t *testing.T) {
+	want := `pass 0 "scene \"main\"" reads [] writes ["hdr \"linear\""]
+pass 1 "present" reads ["hdr \"linear\""] writes []
+culled "debug"
+resource "hdr \"linear\"" 8x4 texture 0
+resource "debug" 8x4 unused
+`
+	if have := quotedGraph(t).String(); have != want {
+		t.Errorf("Want:\n%s\nHave:\n%s", want, have)
+	}
+}
+
+func TestFrameGraphDot(t *testing.T) {
+	want := `digraph framegraph {
+	"pass:scene \"main\"" [shape=box, style=solid, label="scene \"main\""];
+	"pass:debug" [shape=box, style=dashed, label="debug"];
+	"pass:present" [shape=box, style=solid, label="present"];
+	"res:hdr \"linear\"" [shape=ellipse, label="hdr \"linear\"\n8x4\ntexture 0"];
+	"res:debug" [shape=ellipse, label="debug\n8x4"];
+	"pass:scene \"main\"" -> "res:hdr \"linear\"";
+	"res:hdr \"linear\"" -> "pass:debug";
+	"pass:debug" -> "res:debug";
+	"res:hdr \"linear\"" -> "pass:present";
+}
+`
+	if have := quotedGraph(t).Dot(); have != want {
+		t.Errorf("Want:\n%s\nHave:\n%s", want, have)
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package glmath provides the vector, matrix and quaternion types needed to
+// drive OpenGL without relying on the fixed-function matrix stacks or a live
+// context.
+//
+// All types use float64. Matrices are stored column-major, the layout OpenGL
+// expects, so a Mat4 can be passed to glLoadMatrixd as is, or converted with
+// Float32 for shader uniforms.
+package glmath
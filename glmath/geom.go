@@ -0,0 +1,208 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glmath
+
+import (
+	"math"
+)
+
+// An axis-aligned bounding box.
+type AABB struct{ Min, Max Vec3 }
+
+// EmptyAABB returns a box which contains nothing, to be grown with Extend.
+func EmptyAABB() AABB {
+	inf := math.Inf(1)
+	return AABB{Vec3{inf, inf, inf}, Vec3{-inf, -inf, -inf}}
+}
+
+// Empty reports whether the box contains no points.
+func (b AABB) Empty() bool {
+	return b.Min[0] > b.Max[0] || b.Min[1] > b.Max[1] || b.Min[2] > b.Max[2]
+}
+
+// Extend returns the smallest box containing b and p.
+func (b AABB) Extend(p Vec3) AABB {
+	for i := range p {
+		b.Min[i] = math.Min(b.Min[i], p[i])
+		b.Max[i] = math.Max(b.Max[i], p[i])
+	}
+	return b
+}
+
+// Union returns the smallest box containing b and c.
+func (b AABB) Union(c AABB) AABB {
+	if c.Empty() {
+		return b
+	}
+	return b.Extend(c.Min).Extend(c.Max)
+}
+
+func (b AABB) Center() Vec3 { return b.Min.Add(b.Max).Mul(0.5) }
+func (b AABB) Size() Vec3   { return b.Max.Sub(b.Min) }
+
+// Corners returns the eight corners of the box.
+func (b AABB) Corners() (c [8]Vec3) {
+	for i := range c {
+		for j := 0; j < 3; j++ {
+			if i&(1<<uint(j)) == 0 {
+				c[i][j] = b.Min[j]
+			} else {
+				c[i][j] = b.Max[j]
+			}
+		}
+	}
+	return
+}
+
+func (b AABB) Contains(p Vec3) bool {
+	for i := range p {
+		if p[i] < b.Min[i] || p[i] > b.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b AABB) Intersects(c AABB) bool {
+	for i := 0; i < 3; i++ {
+		if b.Max[i] < c.Min[i] || c.Max[i] < b.Min[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Transform returns the box containing b transformed by m.
+func (b AABB) Transform(m Mat4) AABB {
+	result := EmptyAABB()
+	for _, c := range b.Corners() {
+		result = result.Extend(m.Transform(c))
+	}
+	return result
+}
+
+// A Plane holds the points p for which N.Dot(p) + D == 0.
+type Plane struct {
+	N Vec3
+	D float64
+}
+
+// PlaneFromPoints returns the plane through a, b and c. The normal faces
+// the side from which a, b, c appear counter-clockwise.
+func PlaneFromPoints(a, b, c Vec3) Plane {
+	n := b.Sub(a).Cross(c.Sub(a)).Normalize()
+	return Plane{n, -n.Dot(a)}
+}
+
+// PlaneFromNormal returns the plane with normal n through p.
+func PlaneFromNormal(n, p Vec3) Plane {
+	n = n.Normalize()
+	return Plane{n, -n.Dot(p)}
+}
+
+// Normalize scales the plane equation so that N has unit length.
+func (p Plane) Normalize() Plane {
+	l := p.N.Len()
+	if l == 0 {
+		return p
+	}
+	return Plane{p.N.Mul(1 / l), p.D / l}
+}
+
+// Distance returns the signed distance from the normalized plane p to q,
+// positive on the side N faces.
+func (p Plane) Distance(q Vec3) float64 { return p.N.Dot(q) + p.D }
+
+// A Ray is the half line Origin + t*Dir, t >= 0.
+type Ray struct{ Origin, Dir Vec3 }
+
+// At returns the point at parameter t.
+func (r Ray) At(t float64) Vec3 { return r.Origin.Add(r.Dir.Mul(t)) }
+
+// IntersectPlane returns the parameter at which r hits p. It returns false
+// if the ray is parallel to, or points away from, the plane.
+func (r Ray) IntersectPlane(p Plane) (float64, bool) {
+	denom := p.N.Dot(r.Dir)
+	if denom == 0 {
+		return 0, false
+	}
+	t := -p.Distance(r.Origin) / denom
+	return t, t >= 0
+}
+
+// IntersectAABB returns the parameters at which r enters and leaves b.
+// It returns false if the ray misses the box.
+func (r Ray) IntersectAABB(b AABB) (near, far float64, ok bool) {
+	near, far = 0, math.Inf(1)
+	for i := 0; i < 3; i++ {
+		if r.Dir[i] == 0 {
+			if r.Origin[i] < b.Min[i] || r.Origin[i] > b.Max[i] {
+				return 0, 0, false
+			}
+			continue
+		}
+		t0 := (b.Min[i] - r.Origin[i]) / r.Dir[i]
+		t1 := (b.Max[i] - r.Origin[i]) / r.Dir[i]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		near, far = math.Max(near, t0), math.Min(far, t1)
+		if near > far {
+			return 0, 0, false
+		}
+	}
+	return near, far, true
+}
+
+// A Frustum is the volume bounded by six planes whose normals face inwards,
+// in the order left, right, bottom, top, near, far.
+type Frustum [6]Plane
+
+// FrustumFromMatrix extracts the view frustum of a projection * view matrix.
+// The planes are in the space the matrix transforms from.
+func FrustumFromMatrix(m Mat4) (f Frustum) {
+	row := func(i int) Vec4 { return Vec4{m[i], m[4+i], m[8+i], m[12+i]} }
+	r0, r1, r2, r3 := row(0), row(1), row(2), row(3)
+
+	for i, v := range [6]Vec4{
+		r3.Add(r0), r3.Sub(r0),
+		r3.Add(r1), r3.Sub(r1),
+		r3.Add(r2), r3.Sub(r2),
+	} {
+		f[i] = Plane{Vec3{v[0], v[1], v[2]}, v[3]}.Normalize()
+	}
+	return
+}
+
+// Contains reports whether p is inside the frustum.
+func (f *Frustum) Contains(p Vec3) bool {
+	for i := range f {
+		if f[i].Distance(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsAABB reports whether any part of b may be inside the frustum.
+// It is conservative: boxes near the frustum corners may be reported as
+// intersecting when they are not.
+func (f *Frustum) IntersectsAABB(b AABB) bool {
+	for i := range f {
+		// The corner furthest along the plane normal.
+		var p Vec3
+		for j := 0; j < 3; j++ {
+			if f[i].N[j] >= 0 {
+				p[j] = b.Max[j]
+			} else {
+				p[j] = b.Min[j]
+			}
+		}
+		if f[i].Distance(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
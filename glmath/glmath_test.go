@@ -0,0 +1,182 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glmath
+
+import (
+	"math"
+	"testing"
+)
+
+const epsilon = 1e-9
+
+func near(a, b float64) bool { return math.Abs(a-b) < epsilon }
+
+func nearVec3(a, b Vec3) bool {
+	return near(a[0], b[0]) && near(a[1], b[1]) && near(a[2], b[2])
+}
+
+func nearMat4(a, b Mat4) bool {
+	for i := range a {
+		if !near(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMat4Inverse(t *testing.T) {
+	tests := [...]Mat4{
+		Ident4(),
+		Translate(Vec3{1, 2, 3}),
+		Scale(Vec3{2, 3, 4}).Mul(Rotate(0.5, Vec3{1, 1, 0})),
+		Perspective(60, 4.0/3, 0.1, 100),
+		LookAt(Vec3{1, 2, 3}, Vec3{0, 0, 0}, Vec3{0, 1, 0}),
+	}
+
+	for i, m := range tests {
+		inv, ok := m.Inverse()
+		if !ok {
+			t.Fatalf("%d: matrix reported singular", i)
+		}
+		if have := m.Mul(inv); !nearMat4(have, Ident4()) {
+			t.Fatalf("%d: m * inverse(m): Want identity, Have %v", i, have)
+		}
+	}
+
+	if _, ok := (Mat4{}).Inverse(); ok {
+		t.Fatalf("zero matrix reported invertible")
+	}
+}
+
+func TestMat3Inverse(t *testing.T) {
+	m := Rotate(1, Vec3{0, 1, 1}).Mat3().Mul(Mat3{2, 0, 0, 0, 3, 0, 0, 0, 4})
+	inv, ok := m.Inverse()
+	if !ok {
+		t.Fatalf("matrix reported singular")
+	}
+	if have := m.Mul(inv); !nearMat4(have.Mat4(), Ident4()) {
+		t.Fatalf("m * inverse(m): Want identity, Have %v", have)
+	}
+}
+
+func TestLookAt(t *testing.T) {
+	m := LookAt(Vec3{0, 0, 5}, Vec3{0, 0, 0}, Vec3{0, 1, 0})
+
+	tests := [...][2]Vec3{
+		{{0, 0, 0}, {0, 0, -5}},
+		{{1, 0, 0}, {1, 0, -5}},
+		{{0, 0, 5}, {0, 0, 0}},
+	}
+
+	for _, test := range tests {
+		if have := m.Transform(test[0]); !nearVec3(have, test[1]) {
+			t.Fatalf("LookAt transform %v: Want %v, Have %v", test[0], test[1], have)
+		}
+	}
+}
+
+func TestProjectUnProject(t *testing.T) {
+	modelview := LookAt(Vec3{3, 4, 5}, Vec3{0, 0, 0}, Vec3{0, 1, 0})
+	projection := Perspective(45, 1.5, 1, 50)
+	viewport := [4]int{10, 20, 300, 200}
+
+	for _, p := range [...]Vec3{{0, 0, 0}, {1, -1, 0.5}, {-2, 0.3, 1}} {
+		win := Project(p, modelview, projection, viewport)
+		have, ok := UnProject(win, modelview, projection, viewport)
+		if !ok || !nearVec3(have, p) {
+			t.Fatalf("UnProject(Project(%v)): Want %v, Have %v", p, p, have)
+		}
+	}
+
+	// The center of the view volume maps onto the center of the viewport.
+	win := Project(Vec3{0, 0, 0}, modelview, projection, viewport)
+	if !near(win[0], 160) || !near(win[1], 120) {
+		t.Fatalf("Project center: Want (160, 120), Have %v", win)
+	}
+}
+
+func TestQuat(t *testing.T) {
+	q := QuatRotate(math.Pi/2, Vec3{0, 0, 1})
+
+	if have := q.Rotate(Vec3{1, 0, 0}); !nearVec3(have, Vec3{0, 1, 0}) {
+		t.Fatalf("Rotate: Want (0, 1, 0), Have %v", have)
+	}
+	if have := q.Mat4().Transform(Vec3{1, 0, 0}); !nearVec3(have, Vec3{0, 1, 0}) {
+		t.Fatalf("Mat4: Want (0, 1, 0), Have %v", have)
+	}
+
+	half := QuatIdent().Slerp(q, 0.5)
+	want := QuatRotate(math.Pi/4, Vec3{0, 0, 1})
+	if !near(half.Dot(want), 1) {
+		t.Fatalf("Slerp: Want %v, Have %v", want, half)
+	}
+
+	if have := q.Mul(q.Inverse()); !near(have.W, 1) || !nearVec3(have.V, Vec3{}) {
+		t.Fatalf("q * inverse(q): Want identity, Have %v", have)
+	}
+}
+
+func TestDecompose(t *testing.T) {
+	translation := Vec3{1, -2, 3}
+	rotation := QuatRotate(0.7, Vec3{1, 2, 3})
+	scale := Vec3{2, 0.5, 3}
+
+	m := Translate(translation).Mul(rotation.Mat4()).Mul(Scale(scale))
+	tr, r, s := m.Decompose()
+
+	if !nearVec3(tr, translation) {
+		t.Fatalf("translation: Want %v, Have %v", translation, tr)
+	}
+	if !near(math.Abs(r.Dot(rotation)), 1) {
+		t.Fatalf("rotation: Want %v, Have %v", rotation, r)
+	}
+	if !nearVec3(s, scale) {
+		t.Fatalf("scale: Want %v, Have %v", scale, s)
+	}
+}
+
+func TestFrustum(t *testing.T) {
+	m := Perspective(90, 1, 1, 10).Mul(LookAt(Vec3{}, Vec3{0, 0, -1}, Vec3{0, 1, 0}))
+	f := FrustumFromMatrix(m)
+
+	tests := [...]struct {
+		In  Vec3
+		Out bool
+	}{
+		{Vec3{0, 0, -5}, true},
+		{Vec3{0, 0, 5}, false},
+		{Vec3{0, 0, -0.5}, false},
+		{Vec3{0, 0, -11}, false},
+		{Vec3{4, 0, -5}, true},
+		{Vec3{6, 0, -5}, false},
+	}
+
+	for _, test := range tests {
+		if have := f.Contains(test.In); have != test.Out {
+			t.Fatalf("Contains(%v): Want %v, Have %v", test.In, test.Out, have)
+		}
+	}
+
+	if !f.IntersectsAABB(AABB{Vec3{5, -1, -6}, Vec3{7, 1, -4}}) {
+		t.Fatalf("IntersectsAABB: box straddling the right plane not reported")
+	}
+	if f.IntersectsAABB(AABB{Vec3{-1, -1, 1}, Vec3{1, 1, 2}}) {
+		t.Fatalf("IntersectsAABB: box behind the camera reported")
+	}
+}
+
+func TestRay(t *testing.T) {
+	r := Ray{Vec3{0, 0, 5}, Vec3{0, 0, -1}}
+
+	tn, tf, ok := r.IntersectAABB(AABB{Vec3{-1, -1, -1}, Vec3{1, 1, 1}})
+	if !ok || !near(tn, 4) || !near(tf, 6) {
+		t.Fatalf("IntersectAABB: Want 4, 6, true, Have %v, %v, %v", tn, tf, ok)
+	}
+
+	tp, ok := r.IntersectPlane(PlaneFromNormal(Vec3{0, 0, 1}, Vec3{0, 0, 2}))
+	if !ok || !near(tp, 3) {
+		t.Fatalf("IntersectPlane: Want 3, true, Have %v, %v", tp, ok)
+	}
+}
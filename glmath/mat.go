@@ -0,0 +1,326 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glmath
+
+import (
+	"math"
+)
+
+// A 3x3 column-major matrix.
+type Mat3 [9]float64
+
+// A 4x4 column-major matrix. Element m[c*4+r] is in column c, row r.
+type Mat4 [16]float64
+
+// Ident3 returns the 3x3 identity matrix.
+func Ident3() Mat3 {
+	return Mat3{
+		1, 0, 0,
+		0, 1, 0,
+		0, 0, 1,
+	}
+}
+
+// Ident4 returns the 4x4 identity matrix.
+func Ident4() Mat4 {
+	return Mat4{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// At returns the element in row r, column c.
+func (m Mat3) At(r, c int) float64 { return m[c*3+r] }
+
+// Mul returns m * n.
+func (m Mat3) Mul(n Mat3) (r Mat3) {
+	for c := 0; c < 3; c++ {
+		for i := 0; i < 3; i++ {
+			r[c*3+i] = m[i]*n[c*3] + m[3+i]*n[c*3+1] + m[6+i]*n[c*3+2]
+		}
+	}
+	return
+}
+
+// MulVec returns m * v.
+func (m Mat3) MulVec(v Vec3) Vec3 {
+	return Vec3{
+		m[0]*v[0] + m[3]*v[1] + m[6]*v[2],
+		m[1]*v[0] + m[4]*v[1] + m[7]*v[2],
+		m[2]*v[0] + m[5]*v[1] + m[8]*v[2],
+	}
+}
+
+func (m Mat3) Transpose() Mat3 {
+	return Mat3{
+		m[0], m[3], m[6],
+		m[1], m[4], m[7],
+		m[2], m[5], m[8],
+	}
+}
+
+func (m Mat3) Det() float64 {
+	return m[0]*(m[4]*m[8]-m[7]*m[5]) -
+		m[3]*(m[1]*m[8]-m[7]*m[2]) +
+		m[6]*(m[1]*m[5]-m[4]*m[2])
+}
+
+// Inverse returns the inverse of m. It returns false if m is singular.
+func (m Mat3) Inverse() (Mat3, bool) {
+	det := m.Det()
+	if det == 0 {
+		return Mat3{}, false
+	}
+	d := 1 / det
+	return Mat3{
+		(m[4]*m[8] - m[7]*m[5]) * d,
+		(m[7]*m[2] - m[1]*m[8]) * d,
+		(m[1]*m[5] - m[4]*m[2]) * d,
+		(m[6]*m[5] - m[3]*m[8]) * d,
+		(m[0]*m[8] - m[6]*m[2]) * d,
+		(m[3]*m[2] - m[0]*m[5]) * d,
+		(m[3]*m[7] - m[6]*m[4]) * d,
+		(m[6]*m[1] - m[0]*m[7]) * d,
+		(m[0]*m[4] - m[3]*m[1]) * d,
+	}, true
+}
+
+// Mat4 embeds m into the upper left of a 4x4 identity matrix.
+func (m Mat3) Mat4() Mat4 {
+	return Mat4{
+		m[0], m[1], m[2], 0,
+		m[3], m[4], m[5], 0,
+		m[6], m[7], m[8], 0,
+		0, 0, 0, 1,
+	}
+}
+
+// Float32 returns m in single precision, e.g. for shader uniforms.
+func (m Mat3) Float32() (r [9]float32) {
+	for i := range m {
+		r[i] = float32(m[i])
+	}
+	return
+}
+
+// At returns the element in row r, column c.
+func (m Mat4) At(r, c int) float64 { return m[c*4+r] }
+
+// Mul returns m * n.
+func (m Mat4) Mul(n Mat4) (r Mat4) {
+	for c := 0; c < 4; c++ {
+		for i := 0; i < 4; i++ {
+			r[c*4+i] = m[i]*n[c*4] + m[4+i]*n[c*4+1] +
+				m[8+i]*n[c*4+2] + m[12+i]*n[c*4+3]
+		}
+	}
+	return
+}
+
+// MulVec returns m * v.
+func (m Mat4) MulVec(v Vec4) (r Vec4) {
+	for i := range r {
+		r[i] = m[i]*v[0] + m[4+i]*v[1] + m[8+i]*v[2] + m[12+i]*v[3]
+	}
+	return
+}
+
+// Transform transforms the point p, including the perspective divide.
+func (m Mat4) Transform(p Vec3) Vec3 { return m.MulVec(p.Vec4(1)).Vec3() }
+
+// TransformDir transforms the direction d, ignoring translation.
+func (m Mat4) TransformDir(d Vec3) Vec3 {
+	return Vec3{
+		m[0]*d[0] + m[4]*d[1] + m[8]*d[2],
+		m[1]*d[0] + m[5]*d[1] + m[9]*d[2],
+		m[2]*d[0] + m[6]*d[1] + m[10]*d[2],
+	}
+}
+
+func (m Mat4) Transpose() (r Mat4) {
+	for c := 0; c < 4; c++ {
+		for i := 0; i < 4; i++ {
+			r[i*4+c] = m[c*4+i]
+		}
+	}
+	return
+}
+
+// Mat3 returns the upper left 3x3 part of m.
+func (m Mat4) Mat3() Mat3 {
+	return Mat3{
+		m[0], m[1], m[2],
+		m[4], m[5], m[6],
+		m[8], m[9], m[10],
+	}
+}
+
+// NormalMatrix returns the inverse transpose of the upper left 3x3 part of
+// m, used to transform surface normals.
+func (m Mat4) NormalMatrix() Mat3 {
+	inv, _ := m.Mat3().Inverse()
+	return inv.Transpose()
+}
+
+// cofactors returns the adjugate of m and its determinant.
+func (m Mat4) cofactors() (inv Mat4, det float64) {
+	inv[0] = m[5]*m[10]*m[15] - m[5]*m[11]*m[14] - m[9]*m[6]*m[15] +
+		m[9]*m[7]*m[14] + m[13]*m[6]*m[11] - m[13]*m[7]*m[10]
+	inv[4] = -m[4]*m[10]*m[15] + m[4]*m[11]*m[14] + m[8]*m[6]*m[15] -
+		m[8]*m[7]*m[14] - m[12]*m[6]*m[11] + m[12]*m[7]*m[10]
+	inv[8] = m[4]*m[9]*m[15] - m[4]*m[11]*m[13] - m[8]*m[5]*m[15] +
+		m[8]*m[7]*m[13] + m[12]*m[5]*m[11] - m[12]*m[7]*m[9]
+	inv[12] = -m[4]*m[9]*m[14] + m[4]*m[10]*m[13] + m[8]*m[5]*m[14] -
+		m[8]*m[6]*m[13] - m[12]*m[5]*m[10] + m[12]*m[6]*m[9]
+	inv[1] = -m[1]*m[10]*m[15] + m[1]*m[11]*m[14] + m[9]*m[2]*m[15] -
+		m[9]*m[3]*m[14] - m[13]*m[2]*m[11] + m[13]*m[3]*m[10]
+	inv[5] = m[0]*m[10]*m[15] - m[0]*m[11]*m[14] - m[8]*m[2]*m[15] +
+		m[8]*m[3]*m[14] + m[12]*m[2]*m[11] - m[12]*m[3]*m[10]
+	inv[9] = -m[0]*m[9]*m[15] + m[0]*m[11]*m[13] + m[8]*m[1]*m[15] -
+		m[8]*m[3]*m[13] - m[12]*m[1]*m[11] + m[12]*m[3]*m[9]
+	inv[13] = m[0]*m[9]*m[14] - m[0]*m[10]*m[13] - m[8]*m[1]*m[14] +
+		m[8]*m[2]*m[13] + m[12]*m[1]*m[10] - m[12]*m[2]*m[9]
+	inv[2] = m[1]*m[6]*m[15] - m[1]*m[7]*m[14] - m[5]*m[2]*m[15] +
+		m[5]*m[3]*m[14] + m[13]*m[2]*m[7] - m[13]*m[3]*m[6]
+	inv[6] = -m[0]*m[6]*m[15] + m[0]*m[7]*m[14] + m[4]*m[2]*m[15] -
+		m[4]*m[3]*m[14] - m[12]*m[2]*m[7] + m[12]*m[3]*m[6]
+	inv[10] = m[0]*m[5]*m[15] - m[0]*m[7]*m[13] - m[4]*m[1]*m[15] +
+		m[4]*m[3]*m[13] + m[12]*m[1]*m[7] - m[12]*m[3]*m[5]
+	inv[14] = -m[0]*m[5]*m[14] + m[0]*m[6]*m[13] + m[4]*m[1]*m[14] -
+		m[4]*m[2]*m[13] - m[12]*m[1]*m[6] + m[12]*m[2]*m[5]
+	inv[3] = -m[1]*m[6]*m[11] + m[1]*m[7]*m[10] + m[5]*m[2]*m[11] -
+		m[5]*m[3]*m[10] - m[9]*m[2]*m[7] + m[9]*m[3]*m[6]
+	inv[7] = m[0]*m[6]*m[11] - m[0]*m[7]*m[10] - m[4]*m[2]*m[11] +
+		m[4]*m[3]*m[10] + m[8]*m[2]*m[7] - m[8]*m[3]*m[6]
+	inv[11] = -m[0]*m[5]*m[11] + m[0]*m[7]*m[9] + m[4]*m[1]*m[11] -
+		m[4]*m[3]*m[9] - m[8]*m[1]*m[7] + m[8]*m[3]*m[5]
+	inv[15] = m[0]*m[5]*m[10] - m[0]*m[6]*m[9] - m[4]*m[1]*m[10] +
+		m[4]*m[2]*m[9] + m[8]*m[1]*m[6] - m[8]*m[2]*m[5]
+
+	det = m[0]*inv[0] + m[1]*inv[4] + m[2]*inv[8] + m[3]*inv[12]
+	return
+}
+
+func (m Mat4) Det() float64 {
+	_, det := m.cofactors()
+	return det
+}
+
+// Inverse returns the inverse of m. It returns false if m is singular.
+func (m Mat4) Inverse() (Mat4, bool) {
+	inv, det := m.cofactors()
+	if det == 0 {
+		return Mat4{}, false
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, true
+}
+
+// Decompose splits an affine transformation into translation, rotation and
+// scale, such that m = Translate(t) * rotation.Mat4() * Scale(s).
+// Negative scale is attributed to the x axis.
+func (m Mat4) Decompose() (t Vec3, rotation Quat, s Vec3) {
+	t = Vec3{m[12], m[13], m[14]}
+
+	x := Vec3{m[0], m[1], m[2]}
+	y := Vec3{m[4], m[5], m[6]}
+	z := Vec3{m[8], m[9], m[10]}
+	s = Vec3{x.Len(), y.Len(), z.Len()}
+	if m.Mat3().Det() < 0 {
+		s[0] = -s[0]
+	}
+
+	var r Mat3
+	for i, axis := range [3]Vec3{x, y, z} {
+		if s[i] != 0 {
+			axis = axis.Mul(1 / s[i])
+		}
+		copy(r[i*3:], axis[:])
+	}
+	rotation = QuatFromMat3(r)
+	return
+}
+
+// Float32 returns m in single precision, e.g. for shader uniforms.
+func (m Mat4) Float32() (r [16]float32) {
+	for i := range m {
+		r[i] = float32(m[i])
+	}
+	return
+}
+
+// Translate returns a translation matrix, like glTranslate.
+func Translate(v Vec3) Mat4 {
+	m := Ident4()
+	m[12], m[13], m[14] = v[0], v[1], v[2]
+	return m
+}
+
+// Scale returns a scale matrix, like glScale.
+func Scale(v Vec3) Mat4 {
+	m := Ident4()
+	m[0], m[5], m[10] = v[0], v[1], v[2]
+	return m
+}
+
+// Rotate returns a rotation of angle radians around axis, like glRotate
+// (which takes degrees).
+func Rotate(angle float64, axis Vec3) Mat4 {
+	return QuatRotate(angle, axis).Mat4()
+}
+
+// Ortho returns an orthographic projection, like glOrtho.
+func Ortho(left, right, bottom, top, near, far float64) Mat4 {
+	m := Ident4()
+	m[0] = 2 / (right - left)
+	m[5] = 2 / (top - bottom)
+	m[10] = -2 / (far - near)
+	m[12] = -(right + left) / (right - left)
+	m[13] = -(top + bottom) / (top - bottom)
+	m[14] = -(far + near) / (far - near)
+	return m
+}
+
+// FrustumProjection returns a perspective projection, like glFrustum.
+func FrustumProjection(left, right, bottom, top, near, far float64) (m Mat4) {
+	m[0] = 2 * near / (right - left)
+	m[5] = 2 * near / (top - bottom)
+	m[8] = (right + left) / (right - left)
+	m[9] = (top + bottom) / (top - bottom)
+	m[10] = -(far + near) / (far - near)
+	m[11] = -1
+	m[14] = -2 * far * near / (far - near)
+	return
+}
+
+// Perspective returns a perspective projection, like gluPerspective.
+// Fovy is the vertical field of view in degrees.
+func Perspective(fovy, aspect, near, far float64) (m Mat4) {
+	f := 1 / math.Tan(fovy*math.Pi/360)
+	m[0] = f / aspect
+	m[5] = f
+	m[10] = (far + near) / (near - far)
+	m[11] = -1
+	m[14] = 2 * far * near / (near - far)
+	return
+}
+
+// LookAt returns a viewing transformation, like gluLookAt.
+func LookAt(eye, center, up Vec3) Mat4 {
+	f := center.Sub(eye).Normalize()
+	s := f.Cross(up).Normalize()
+	u := s.Cross(f)
+
+	return Mat4{
+		s[0], u[0], -f[0], 0,
+		s[1], u[1], -f[1], 0,
+		s[2], u[2], -f[2], 0,
+		-s.Dot(eye), -u.Dot(eye), f.Dot(eye), 1,
+	}
+}
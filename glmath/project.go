@@ -0,0 +1,54 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glmath
+
+// Project maps object co-ordinates to window co-ordinates, like gluProject.
+// The viewport holds x, y, width and height, as returned by
+// glGetIntegerv(GL_VIEWPORT). Window y is measured from the bottom.
+func Project(obj Vec3, modelview, projection Mat4, viewport [4]int) Vec3 {
+	v := projection.Mul(modelview).MulVec(obj.Vec4(1))
+	if v[3] == 0 {
+		return Vec3{}
+	}
+	ndc := Vec3{v[0] / v[3], v[1] / v[3], v[2] / v[3]}
+
+	return Vec3{
+		float64(viewport[0]) + float64(viewport[2])*(ndc[0]+1)/2,
+		float64(viewport[1]) + float64(viewport[3])*(ndc[1]+1)/2,
+		(ndc[2] + 1) / 2,
+	}
+}
+
+// UnProject maps window co-ordinates to object co-ordinates, like
+// gluUnProject. It returns false if the matrices are singular.
+func UnProject(win Vec3, modelview, projection Mat4, viewport [4]int) (Vec3, bool) {
+	inv, ok := projection.Mul(modelview).Inverse()
+	if !ok || viewport[2] == 0 || viewport[3] == 0 {
+		return Vec3{}, false
+	}
+
+	ndc := Vec4{
+		2*(win[0]-float64(viewport[0]))/float64(viewport[2]) - 1,
+		2*(win[1]-float64(viewport[1]))/float64(viewport[3]) - 1,
+		2*win[2] - 1,
+		1,
+	}
+	v := inv.MulVec(ndc)
+	if v[3] == 0 {
+		return Vec3{}, false
+	}
+	return Vec3{v[0] / v[3], v[1] / v[3], v[2] / v[3]}, true
+}
+
+// PickRay returns the ray through window co-ordinate x, y (measured from the
+// bottom), from the near to the far plane.
+func PickRay(x, y float64, modelview, projection Mat4, viewport [4]int) (Ray, bool) {
+	near, ok1 := UnProject(Vec3{x, y, 0}, modelview, projection, viewport)
+	far, ok2 := UnProject(Vec3{x, y, 1}, modelview, projection, viewport)
+	if !ok1 || !ok2 {
+		return Ray{}, false
+	}
+	return Ray{near, far.Sub(near).Normalize()}, true
+}
@@ -0,0 +1,122 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glmath
+
+import (
+	"math"
+)
+
+// A Quat is a quaternion W + V, used to represent rotations.
+type Quat struct {
+	W float64
+	V Vec3
+}
+
+// QuatIdent returns the identity rotation.
+func QuatIdent() Quat { return Quat{W: 1} }
+
+// QuatRotate returns a rotation of angle radians around axis.
+func QuatRotate(angle float64, axis Vec3) Quat {
+	s, c := math.Sincos(angle / 2)
+	return Quat{c, axis.Normalize().Mul(s)}
+}
+
+// QuatFromMat3 returns the rotation described by the orthonormal matrix m.
+func QuatFromMat3(m Mat3) Quat {
+	var q Quat
+	trace := m[0] + m[4] + m[8]
+
+	switch {
+	case trace > 0:
+		s := 0.5 / math.Sqrt(trace+1)
+		q.W = 0.25 / s
+		q.V = Vec3{(m[5] - m[7]) * s, (m[6] - m[2]) * s, (m[1] - m[3]) * s}
+	case m[0] > m[4] && m[0] > m[8]:
+		s := 2 * math.Sqrt(1+m[0]-m[4]-m[8])
+		q.W = (m[5] - m[7]) / s
+		q.V = Vec3{0.25 * s, (m[3] + m[1]) / s, (m[6] + m[2]) / s}
+	case m[4] > m[8]:
+		s := 2 * math.Sqrt(1+m[4]-m[0]-m[8])
+		q.W = (m[6] - m[2]) / s
+		q.V = Vec3{(m[3] + m[1]) / s, 0.25 * s, (m[7] + m[5]) / s}
+	default:
+		s := 2 * math.Sqrt(1+m[8]-m[0]-m[4])
+		q.W = (m[1] - m[3]) / s
+		q.V = Vec3{(m[6] + m[2]) / s, (m[7] + m[5]) / s, 0.25 * s}
+	}
+	return q.Normalize()
+}
+
+func (q Quat) Add(r Quat) Quat      { return Quat{q.W + r.W, q.V.Add(r.V)} }
+func (q Quat) Scale(s float64) Quat { return Quat{q.W * s, q.V.Mul(s)} }
+func (q Quat) Dot(r Quat) float64   { return q.W*r.W + q.V.Dot(r.V) }
+func (q Quat) Len() float64         { return math.Sqrt(q.Dot(q)) }
+func (q Quat) Conjugate() Quat      { return Quat{q.W, q.V.Mul(-1)} }
+
+// Mul returns the rotation q applied after r.
+func (q Quat) Mul(r Quat) Quat {
+	return Quat{
+		q.W*r.W - q.V.Dot(r.V),
+		r.V.Mul(q.W).Add(q.V.Mul(r.W)).Add(q.V.Cross(r.V)),
+	}
+}
+
+// Normalize returns q scaled to unit length.
+func (q Quat) Normalize() Quat {
+	if l := q.Len(); l != 0 {
+		return q.Scale(1 / l)
+	}
+	return q
+}
+
+// Inverse returns the inverse of q.
+func (q Quat) Inverse() Quat {
+	return q.Conjugate().Scale(1 / q.Dot(q))
+}
+
+// Rotate rotates v by the unit quaternion q.
+func (q Quat) Rotate(v Vec3) Vec3 {
+	t := q.V.Cross(v).Mul(2)
+	return v.Add(t.Mul(q.W)).Add(q.V.Cross(t))
+}
+
+// AxisAngle returns the axis and angle in radians of the unit quaternion q.
+func (q Quat) AxisAngle() (Vec3, float64) {
+	angle := 2 * math.Acos(math.Max(-1, math.Min(1, q.W)))
+	return q.V.Normalize(), angle
+}
+
+// Mat3 returns the rotation matrix of the unit quaternion q.
+func (q Quat) Mat3() Mat3 {
+	w, x, y, z := q.W, q.V[0], q.V[1], q.V[2]
+	return Mat3{
+		1 - 2*y*y - 2*z*z, 2*x*y + 2*w*z, 2*x*z - 2*w*y,
+		2*x*y - 2*w*z, 1 - 2*x*x - 2*z*z, 2*y*z + 2*w*x,
+		2*x*z + 2*w*y, 2*y*z - 2*w*x, 1 - 2*x*x - 2*y*y,
+	}
+}
+
+// Mat4 returns the rotation matrix of the unit quaternion q.
+func (q Quat) Mat4() Mat4 { return q.Mat3().Mat4() }
+
+// Slerp spherically interpolates between the unit quaternions q and r,
+// along the shortest path.
+func (q Quat) Slerp(r Quat, t float64) Quat {
+	cos := q.Dot(r)
+	if cos < 0 {
+		r, cos = r.Scale(-1), -cos
+	}
+
+	// Nearly parallel; fall back to linear interpolation.
+	if cos > 0.9995 {
+		return q.Add(r.Add(q.Scale(-1)).Scale(t)).Normalize()
+	}
+
+	theta := math.Acos(cos)
+	sin := math.Sin(theta)
+	a := math.Sin((1-t)*theta) / sin
+	b := math.Sin(t*theta) / sin
+	return q.Scale(a).Add(r.Scale(b))
+}
@@ -0,0 +1,89 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glmath
+
+import (
+	"math"
+)
+
+type Vec2 [2]float64
+type Vec3 [3]float64
+type Vec4 [4]float64
+
+func (a Vec2) Add(b Vec2) Vec2             { return Vec2{a[0] + b[0], a[1] + b[1]} }
+func (a Vec2) Sub(b Vec2) Vec2             { return Vec2{a[0] - b[0], a[1] - b[1]} }
+func (a Vec2) Mul(s float64) Vec2          { return Vec2{a[0] * s, a[1] * s} }
+func (a Vec2) Dot(b Vec2) float64          { return a[0]*b[0] + a[1]*b[1] }
+func (a Vec2) Len() float64                { return math.Sqrt(a.Dot(a)) }
+func (a Vec2) Lerp(b Vec2, t float64) Vec2 { return a.Add(b.Sub(a).Mul(t)) }
+
+// Normalize returns a scaled to unit length. The zero vector is returned
+// unchanged.
+func (a Vec2) Normalize() Vec2 {
+	if l := a.Len(); l != 0 {
+		return a.Mul(1 / l)
+	}
+	return a
+}
+
+func (a Vec3) Add(b Vec3) Vec3    { return Vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]} }
+func (a Vec3) Sub(b Vec3) Vec3    { return Vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+func (a Vec3) Mul(s float64) Vec3 { return Vec3{a[0] * s, a[1] * s, a[2] * s} }
+func (a Vec3) Dot(b Vec3) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+func (a Vec3) Len() float64       { return math.Sqrt(a.Dot(a)) }
+
+func (a Vec3) Lerp(b Vec3, t float64) Vec3 { return a.Add(b.Sub(a).Mul(t)) }
+
+// MulVec multiplies a and b component-wise.
+func (a Vec3) MulVec(b Vec3) Vec3 { return Vec3{a[0] * b[0], a[1] * b[1], a[2] * b[2]} }
+
+func (a Vec3) Cross(b Vec3) Vec3 {
+	return Vec3{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+// Normalize returns a scaled to unit length. The zero vector is returned
+// unchanged.
+func (a Vec3) Normalize() Vec3 {
+	if l := a.Len(); l != 0 {
+		return a.Mul(1 / l)
+	}
+	return a
+}
+
+// Vec4 extends a with the given w component.
+func (a Vec3) Vec4(w float64) Vec4 { return Vec4{a[0], a[1], a[2], w} }
+
+func (a Vec4) Add(b Vec4) Vec4 {
+	return Vec4{a[0] + b[0], a[1] + b[1], a[2] + b[2], a[3] + b[3]}
+}
+func (a Vec4) Sub(b Vec4) Vec4 {
+	return Vec4{a[0] - b[0], a[1] - b[1], a[2] - b[2], a[3] - b[3]}
+}
+func (a Vec4) Mul(s float64) Vec4 { return Vec4{a[0] * s, a[1] * s, a[2] * s, a[3] * s} }
+func (a Vec4) Dot(b Vec4) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3] }
+func (a Vec4) Len() float64       { return math.Sqrt(a.Dot(a)) }
+
+func (a Vec4) Lerp(b Vec4, t float64) Vec4 { return a.Add(b.Sub(a).Mul(t)) }
+
+// Normalize returns a scaled to unit length. The zero vector is returned
+// unchanged.
+func (a Vec4) Normalize() Vec4 {
+	if l := a.Len(); l != 0 {
+		return a.Mul(1 / l)
+	}
+	return a
+}
+
+// Vec3 returns the first three components of a, divided by w unless w is 0.
+func (a Vec4) Vec3() Vec3 {
+	if a[3] == 0 || a[3] == 1 {
+		return Vec3{a[0], a[1], a[2]}
+	}
+	return Vec3{a[0] / a[3], a[1] / a[3], a[2] / a[3]}
+}
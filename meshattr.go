@@ -24,16 +24,19 @@ const (
 // An Attr describes the type and size of a single vertex component.
 // These tell the MeshBuffer how to interpret mesh data.
 type Attr struct {
-	data    interface{} // Data store.
-	name    string      // Attribute name.
-	vbo     gl.Buffer   // Vertex buffer identity.
-	target  gl.GLenum   // Buffer type.
-	usage   gl.GLenum   // Usage type of this attribute.
-	typ     gl.GLenum   // Attribute type.
-	size    int         // Component size (number of elements).
-	stride  int         // Size of component in bytes.
-	gpuSize int         // Size of data on GPU.
-	invalid bool        // Do we require re-committing?
+	data       interface{}       // Data store.
+	name       string            // Attribute name.
+	vbo        gl.Buffer         // Vertex buffer identity.
+	target     gl.GLenum         // Buffer type.
+	usage      gl.GLenum         // Usage type of this attribute.
+	typ        gl.GLenum         // Attribute type.
+	size       int               // Component size (number of elements).
+	stride     int               // Size of component in bytes.
+	gpuSize    int               // Size of data on GPU.
+	invalid    bool              // Do we require re-committing?
+	location   gl.AttribLocation // Shader attribute location; RenderShader only.
+	normalized bool              // Normalize integer data; RenderShader only.
+	offset     int               // Byte offset in an interleaved vertex.
 }
 
 // NewAttr creates a new mesh attribute for the given size,
@@ -48,6 +51,7 @@ type Attr struct {
 func NewAttr(name string, size int, typ, usage gl.GLenum) *Attr {
 	a := new(Attr)
 	a.name = name
+	a.location = -1
 
 	if size == 0 {
 		return a
@@ -156,6 +160,22 @@ func (a *Attr) Invalid() bool { return a.invalid }
 // It will be re-committed on the next render pass.
 func (a *Attr) Invalidate() { a.invalid = true }
 
+// Normalized returns true if integer data is mapped to [0, 1], or [-1, 1]
+// for signed types, when passed to a shader in RenderShader mode.
+func (a *Attr) Normalized() bool { return a.normalized }
+
+// SetNormalized sets whether integer data is normalized in RenderShader mode.
+// This is typically wanted for colors stored as bytes.
+func (a *Attr) SetNormalized(n bool) { a.normalized = n }
+
+// Location returns the location of the attribute in the program of a
+// RenderShader mode MeshBuffer, or -1 if the program does not use it.
+func (a *Attr) Location() gl.AttribLocation { return a.location }
+
+// Offset returns the byte offset of the attribute within a vertex, when
+// the MeshBuffer uses an interleaved layout.
+func (a *Attr) Offset() int { return a.offset }
+
 // Size returns the number of elements in a vertext component for this attribute.
 func (a *Attr) Size() int { return a.size }
 
@@ -178,8 +198,11 @@ func (a *Attr) Target() gl.GLenum { return a.target }
 func (a *Attr) SetTarget(t gl.GLenum) { a.target = t }
 
 // Len returns the number of elements in the data store.
-func (a *Attr) Len() int {
-	switch v := a.data.(type) {
+func (a *Attr) Len() int { return sliceLen(a.data) }
+
+// sliceLen returns the length of a slice of one of the attribute types.
+func sliceLen(data interface{}) int {
+	switch v := data.(type) {
 	case []int8:
 		return len(v)
 	case []uint8:
@@ -283,41 +306,74 @@ func (a *Attr) buffer() {
 	a.invalid = false
 }
 
-// increment increments the value at the given range by the supplied value.
-// This is used internally by the mesh buffer.
-func (a *Attr) increment(start int, value float64) {
+// bytes returns the data store as raw bytes.
+// Used to build interleaved vertex data.
+func (a *Attr) bytes() []byte {
+	n := a.Len() * a.stride
+	if n == 0 {
+		return nil
+	}
+
+	var p unsafe.Pointer
 	switch v := a.data.(type) {
 	case []int8:
-		for i := start; i < len(v); i++ {
+		p = unsafe.Pointer(&v[0])
+	case []uint8:
+		p = unsafe.Pointer(&v[0])
+	case []int16:
+		p = unsafe.Pointer(&v[0])
+	case []uint16:
+		p = unsafe.Pointer(&v[0])
+	case []int32:
+		p = unsafe.Pointer(&v[0])
+	case []uint32:
+		p = unsafe.Pointer(&v[0])
+	case []float32:
+		p = unsafe.Pointer(&v[0])
+	case []float64:
+		p = unsafe.Pointer(&v[0])
+	}
+
+	return (*[1 << 30]byte)(p)[:n:n]
+}
+
+// increment increments the values in the given range by the supplied value.
+// A negative value decrements them; for unsigned types the conversion wraps
+// around, so the addition still subtracts. This is used internally by the
+// mesh buffer.
+func (a *Attr) increment(start, end, value int) {
+	switch v := a.data.(type) {
+	case []int8:
+		for i := start; i < end; i++ {
 			v[i] += int8(value)
 		}
 	case []uint8:
-		for i := start; i < len(v); i++ {
+		for i := start; i < end; i++ {
 			v[i] += uint8(value)
 		}
 	case []int16:
-		for i := start; i < len(v); i++ {
+		for i := start; i < end; i++ {
 			v[i] += int16(value)
 		}
 	case []uint16:
-		for i := start; i < len(v); i++ {
+		for i := start; i < end; i++ {
 			v[i] += uint16(value)
 		}
 	case []int32:
-		for i := start; i < len(v); i++ {
+		for i := start; i < end; i++ {
 			v[i] += int32(value)
 		}
 	case []uint32:
-		for i := start; i < len(v); i++ {
+		for i := start; i < end; i++ {
 			v[i] += uint32(value)
 		}
 	case []float32:
-		for i := start; i < len(v); i++ {
+		for i := start; i < end; i++ {
 			v[i] += float32(value)
 		}
 	case []float64:
-		for i := start; i < len(v); i++ {
-			v[i] += value
+		for i := start; i < end; i++ {
+			v[i] += float64(value)
 		}
 	}
 
@@ -375,6 +431,55 @@ func (a *Attr) append(data interface{}) int {
 	return n
 }
 
+// replace overwrites the elements at the given offset with the given slice.
+// We expect a slice of the appropriate type. E.g.: []uint8, []float32, etc.
+func (a *Attr) replace(start int, data interface{}) {
+	switch va := a.data.(type) {
+	case []int8:
+		copy(va[start:], data.([]int8))
+	case []uint8:
+		copy(va[start:], data.([]uint8))
+	case []int16:
+		copy(va[start:], data.([]int16))
+	case []uint16:
+		copy(va[start:], data.([]uint16))
+	case []int32:
+		copy(va[start:], data.([]int32))
+	case []uint32:
+		copy(va[start:], data.([]uint32))
+	case []float32:
+		copy(va[start:], data.([]float32))
+	case []float64:
+		copy(va[start:], data.([]float64))
+	}
+
+	a.invalid = true
+}
+
+// remove removes count elements at the given offset from the data store.
+func (a *Attr) remove(start, count int) {
+	switch v := a.data.(type) {
+	case []int8:
+		a.data = append(v[:start], v[start+count:]...)
+	case []uint8:
+		a.data = append(v[:start], v[start+count:]...)
+	case []int16:
+		a.data = append(v[:start], v[start+count:]...)
+	case []uint16:
+		a.data = append(v[:start], v[start+count:]...)
+	case []int32:
+		a.data = append(v[:start], v[start+count:]...)
+	case []uint32:
+		a.data = append(v[:start], v[start+count:]...)
+	case []float32:
+		a.data = append(v[:start], v[start+count:]...)
+	case []float64:
+		a.data = append(v[:start], v[start+count:]...)
+	}
+
+	a.invalid = true
+}
+
 // Ptr returns a pointer to the element indicated by index.
 // Used in RenderArrays mode.
 func (a *Attr) ptr(index int) uintptr {
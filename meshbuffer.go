@@ -5,12 +5,18 @@
 package glh
 
 import (
+	"reflect"
+
 	"github.com/go-gl/gl"
 )
 
 // Mesh describes the data offsets for a single mesh inside a mesh buffer.
 type Mesh map[string][2]int
 
+// A MeshHandle identifies a mesh in a MeshBuffer. Unlike a mesh index, it
+// stays the same when other meshes are removed.
+type MeshHandle int
+
 // A RenderMode determines how a MeshBuffer should buffer and render mesh data.
 type RenderMode uint8
 
@@ -32,15 +38,51 @@ const (
 	// where shader support is not present or deemed necessary. This implies
 	// OpenGL version 2.1+.
 	RenderBuffered
+
+	// Shader mode uses VBO's bound to generic vertex attributes of a shader
+	// program, set with MeshBuffer.SetProgram. Attribute names refer to the
+	// attribute variables of the program, and no fixed function state is
+	// used. This implies OpenGL version 2.1+.
+	RenderShader
 )
 
 // MeshBuffer represents a mesh buffer. It caches and renders vertex data
 // for an arbitrary amount of independent meshes.
 type MeshBuffer struct {
-	meshes []Mesh     // List of mesh descriptors.
-	attr   []*Attr    // List of attributes.
-	mesh   Mesh       // Internal mesh, representing all data.
-	mode   RenderMode // Current render mode.
+	meshes  []Mesh       // List of mesh descriptors.
+	handles []MeshHandle // Handle of each mesh.
+	next    MeshHandle   // Next mesh handle.
+	attr    []*Attr      // List of attributes.
+	mesh    Mesh         // Internal mesh, representing all data.
+	mode    RenderMode   // Current render mode.
+	program gl.Program   // Program used in RenderShader mode.
+
+	useVAO    bool           // Record the attribute layout in a VAO?
+	vao       gl.VertexArray // Vertex array object, if recorded.
+	vaoLayout []attrLayout   // Attribute layout recorded in vao.
+
+	// Reports whether the context supports VAOs. Replaceable for testing.
+	vaoSupported func() bool
+
+	interleaved bool      // Pack vertex attributes into a single VBO?
+	vbo         gl.Buffer // Interleaved vertex buffer.
+	packed      []byte    // Interleaved vertex data.
+	vstride     int       // Size of an interleaved vertex in bytes.
+	gpuSize     int       // Size of the interleaved data on the GPU.
+	dirty       bool      // Does the interleaved data need re-committing?
+}
+
+// attrLayout is the state of an attribute which is recorded in a VAO.
+type attrLayout struct {
+	vbo        gl.Buffer
+	target     gl.GLenum
+	typ        gl.GLenum
+	size       int
+	normalized bool
+	location   gl.AttribLocation
+	offset     int
+	stride     int
+	empty      bool
 }
 
 // NewMeshBuffer returns a new mesh buffer object.
@@ -65,13 +107,23 @@ type MeshBuffer struct {
 //        NewColorAttr(4, gl.FLOAT, gl.DYNAMIC_DRAW),
 //    )
 //
+// In RenderShader mode, attributes may have any name, matching the attribute
+// variables of the program given to SetProgram:
+//
+//    mb := NewMeshBuffer(
+//        glh.RenderShader,
+//        NewAttr("vertex", 3, gl.FLOAT, gl.STATIC_DRAW),
+//        NewAttr("uv", 2, gl.FLOAT, gl.STATIC_DRAW),
+//    )
+//    mb.SetProgram(program)
+//
 // Any mesh data loaded into this buffer through MeshBuffer.Add(), must adhere
 // to the format defined by these attributes. THis includes the order in
 // which the data is supplied. It must match the order in which the attributes
 // are defined here.
 func NewMeshBuffer(mode RenderMode, attr ...*Attr) *MeshBuffer {
 	switch mode {
-	case RenderClassic, RenderArrays, RenderBuffered:
+	case RenderClassic, RenderArrays, RenderBuffered, RenderShader:
 	default:
 		panic("Invalid render mode.")
 	}
@@ -80,8 +132,28 @@ func NewMeshBuffer(mode RenderMode, attr ...*Attr) *MeshBuffer {
 	mb.mode = mode
 	mb.attr = attr
 	mb.mesh = make(Mesh)
+	mb.vaoSupported = vertexArraysSupported
+
+	if mode == RenderShader {
+		// Attributes may have any name. The first one other than the
+		// indices provides the vertex count.
+		if mb.vertexAttr() == nil {
+			panic("RenderShader mode requires at least one vertex attribute with size > 0")
+		}
+
+		if mb.find(mbIndexKey) == nil {
+			mb.attr = append(mb.attr, NewIndexAttr(0, 0, 0))
+		}
+
+		for _, attr := range mb.attr {
+			mb.mesh[attr.name] = [2]int{0, 0}
+			attr.init(mode)
+		}
 
-	// All current modes expect the attributes to adhere to some requirements.
+		return mb
+	}
+
+	// All other modes expect the attributes to adhere to some requirements.
 	// We require at least a position attribute. Other accepted attributes are
 	// for indices, vertex colors, vertex texture coordinates and surface normals.
 
@@ -116,6 +188,22 @@ func NewMeshBuffer(mode RenderMode, attr ...*Attr) *MeshBuffer {
 
 // Release releases all resources for this buffer.
 func (mb *MeshBuffer) Release() {
+	if mb.vao != 0 {
+		mb.vao.Delete()
+		mb.vao = 0
+	}
+	mb.vaoLayout = nil
+
+	if mb.interleaved {
+		// The attributes share our buffer.
+		for _, attr := range mb.packedAttrs() {
+			attr.vbo = 0
+		}
+		mb.vbo.Delete()
+		mb.vbo = 0
+		mb.packed = nil
+	}
+
 	for i := range mb.attr {
 		mb.attr[i].release()
 		mb.attr[i] = nil
@@ -124,6 +212,7 @@ func (mb *MeshBuffer) Release() {
 	mb.mesh = nil
 	mb.attr = nil
 	mb.meshes = nil
+	mb.handles = nil
 }
 
 // Clear clears the mesh buffer.
@@ -136,7 +225,381 @@ func (mb *MeshBuffer) Clear() {
 		mb.mesh[key] = [2]int{0, 0}
 	}
 
+	if mb.interleaved {
+		mb.packed = mb.packed[:0]
+		mb.dirty = true
+	}
+
 	mb.meshes = mb.meshes[:0]
+	mb.handles = mb.handles[:0]
+}
+
+// SetProgram sets the shader program used in RenderShader mode, and looks
+// up the locations of the attributes in it. Attributes which the program
+// does not use are not bound.
+func (mb *MeshBuffer) SetProgram(p gl.Program) {
+	mb.program = p
+	for _, attr := range mb.attr {
+		attr.location = p.GetAttribLocation(attr.name)
+	}
+}
+
+// Program returns the shader program used in RenderShader mode.
+func (mb *MeshBuffer) Program() gl.Program { return mb.program }
+
+// SetVertexArray sets whether the attribute layout is recorded in a vertex
+// array object, so rendering is a single VAO bind and draw call instead of
+// setting up every attribute. The layout is re-recorded when an attribute
+// changes. Only RenderBuffered and RenderShader modes support this, and only
+// with OpenGL 3.0 or ARB_vertex_array_object; otherwise buffers render as
+// before. Returns true if vertex array objects are used.
+func (mb *MeshBuffer) SetVertexArray(enable bool) bool {
+	switch {
+	case mb.mode != RenderBuffered && mb.mode != RenderShader:
+		enable = false
+	case enable && !mb.vaoSupported():
+		enable = false
+	}
+
+	if !enable && mb.vao != 0 {
+		mb.vao.Delete()
+		mb.vao = 0
+		mb.vaoLayout = nil
+	}
+	mb.useVAO = enable
+	return enable
+}
+
+// VertexArray returns true if the buffer renders using a vertex array object.
+func (mb *MeshBuffer) VertexArray() bool { return mb.useVAO }
+
+// SetInterleaved sets whether the vertex attributes are packed into a single
+// VBO, storing the components of each vertex next to each other, instead of
+// one VBO per attribute. Indices are always stored separately. Add and the
+// attribute data stores work the same with either layout, so both can be
+// compared on the same data. In the interleaved layout, every mesh must
+// supply the same number of vertices for all vertex attributes.
+//
+// Only RenderBuffered and RenderShader modes support this. Returns true if
+// the layout is interleaved.
+func (mb *MeshBuffer) SetInterleaved(enable bool) bool {
+	if mb.mode != RenderBuffered && mb.mode != RenderShader {
+		enable = false
+	}
+
+	if enable == mb.interleaved {
+		return enable
+	}
+
+	attrs := mb.packedAttrs()
+
+	if enable {
+		mb.vbo = gl.GenBuffer()
+		for _, attr := range attrs {
+			attr.vbo.Delete()
+			attr.vbo = mb.vbo
+		}
+	} else {
+		for _, attr := range attrs {
+			attr.vbo = gl.GenBuffer()
+		}
+		mb.vbo.Delete()
+		mb.vbo = 0
+	}
+
+	mb.setInterleaved(enable)
+	return enable
+}
+
+// setInterleaved computes the attribute offsets for the given layout, and
+// invalidates the attributes so they are buffered again.
+func (mb *MeshBuffer) setInterleaved(enable bool) {
+	attrs := mb.packedAttrs()
+	sizes := make([]int, len(attrs))
+	for i, attr := range attrs {
+		sizes[i] = attr.size * attr.stride
+	}
+
+	offsets, stride := interleavedLayout(sizes)
+
+	for i, attr := range attrs {
+		attr.offset = 0
+		if enable {
+			attr.offset = offsets[i]
+		}
+		attr.gpuSize = 0
+		attr.Invalidate()
+	}
+
+	mb.vstride = 0
+	if enable {
+		mb.vstride = stride
+	}
+	mb.packed = nil
+	mb.gpuSize = 0
+	mb.interleaved = enable
+}
+
+// Interleaved returns true if the vertex attributes are packed into a
+// single VBO.
+func (mb *MeshBuffer) Interleaved() bool { return mb.interleaved }
+
+// packedAttrs returns the attributes which are packed in the interleaved
+// layout: all except the indices.
+func (mb *MeshBuffer) packedAttrs() []*Attr {
+	var list []*Attr
+	for _, attr := range mb.attr {
+		if attr.name != mbIndexKey && attr.size > 0 {
+			list = append(list, attr)
+		}
+	}
+	return list
+}
+
+// interleavedLayout returns the offset of each component within an
+// interleaved vertex, given the component sizes in bytes, and the size of
+// the vertex. Components are aligned to 4 bytes.
+func interleavedLayout(sizes []int) (offsets []int, stride int) {
+	offsets = make([]int, len(sizes))
+	for i, size := range sizes {
+		offsets[i] = stride
+		stride += (size + 3) &^ 3
+	}
+	return offsets, stride
+}
+
+// interleave packs count vertices from the data stores of the given
+// attributes into dst, starting at the given vertex. Returns the updated
+// slice, which is grown to hold start+count vertices if shorter.
+func interleave(dst []byte, stride, start, count int, attrs []*Attr) []byte {
+	end := (start + count) * stride
+	if end < len(dst) {
+		end = len(dst)
+	}
+	if end > cap(dst) {
+		grown := make([]byte, end, 2*end)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:end]
+
+	for _, attr := range attrs {
+		src := attr.bytes()
+		size := attr.size * attr.stride
+
+		for v := start; v < start+count; v++ {
+			copy(dst[v*stride+attr.offset:], src[v*size:(v+1)*size])
+		}
+	}
+
+	return dst
+}
+
+// pack appends the vertices of a newly added mesh to the interleaved data.
+// If the data was already invalid, it is repacked entirely by commit.
+func (mb *MeshBuffer) pack(m Mesh, invalid bool) {
+	attrs := mb.packedAttrs()
+	r := m[attrs[0].name]
+
+	for _, attr := range attrs[1:] {
+		if m[attr.name][1] != r[1] {
+			panic("Interleaved layout requires equal vertex counts for attribute: " + attr.name)
+		}
+	}
+
+	if invalid || r[1] == 0 {
+		return
+	}
+
+	mb.packed = interleave(mb.packed, mb.vstride, r[0], r[1], attrs)
+	for _, attr := range attrs {
+		attr.invalid = false
+	}
+	mb.dirty = true
+}
+
+// packInvalid returns true if any of the interleaved attributes was
+// invalidated.
+func (mb *MeshBuffer) packInvalid() bool {
+	for _, attr := range mb.packedAttrs() {
+		if attr.Invalid() {
+			return true
+		}
+	}
+	return false
+}
+
+// repack packs all interleaved data again if an attribute was invalidated.
+func (mb *MeshBuffer) repack() {
+	if !mb.packInvalid() {
+		return
+	}
+
+	attrs := mb.packedAttrs()
+	count := attrs[0].Len() / attrs[0].size
+	for _, attr := range attrs[1:] {
+		if attr.Len()/attr.size != count {
+			panic("Interleaved layout requires equal vertex counts for attribute: " + attr.name)
+		}
+	}
+
+	mb.packed = interleave(mb.packed[:0], mb.vstride, 0, count, attrs)
+	for _, attr := range attrs {
+		attr.invalid = false
+	}
+	mb.dirty = true
+}
+
+// commit repacks the interleaved data if an attribute was invalidated, and
+// buffers it on the GPU if it changed.
+func (mb *MeshBuffer) commit() {
+	mb.repack()
+
+	if !mb.dirty || len(mb.packed) == 0 {
+		return
+	}
+
+	size := len(mb.packed)
+
+	mb.vbo.Bind(gl.ARRAY_BUFFER)
+	if size != mb.gpuSize {
+		gl.BufferData(gl.ARRAY_BUFFER, size, mb.packed, mb.packedAttrs()[0].usage)
+		mb.gpuSize = size
+	} else {
+		gl.BufferSubData(gl.ARRAY_BUFFER, 0, size, mb.packed)
+	}
+	mb.vbo.Unbind(gl.ARRAY_BUFFER)
+
+	mb.dirty = false
+}
+
+// vertexArraysSupported returns true if the context supports vertex array
+// objects.
+func vertexArraysSupported() bool {
+	if major, _ := GLVersion(); major >= 3 {
+		return true
+	}
+	return HasExtension("GL_ARB_vertex_array_object")
+}
+
+// layout returns the current layout of the attributes, to detect when the
+// VAO needs to be re-recorded.
+func (mb *MeshBuffer) layout() []attrLayout {
+	l := make([]attrLayout, len(mb.attr))
+	for i, a := range mb.attr {
+		l[i] = attrLayout{a.vbo, a.target, a.typ, a.size, a.normalized,
+			a.location, a.offset, mb.vstride, a.Len() == 0}
+	}
+	return l
+}
+
+func layoutEqual(a, b []attrLayout) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// recordVAO records the attribute layout in a new vertex array object.
+func (mb *MeshBuffer) recordVAO(layout []attrLayout) {
+	if mb.vao != 0 {
+		mb.vao.Delete()
+	}
+	mb.vao = gl.GenVertexArray()
+	mb.vao.Bind()
+
+	for _, attr := range mb.attr {
+		if attr.size == 0 || attr.Len() == 0 || attr.name == mbIndexKey {
+			continue
+		}
+		if mb.mode == RenderShader && attr.location < 0 {
+			continue
+		}
+
+		attr.bind()
+		switch {
+		case mb.mode == RenderShader:
+			attr.location.EnableArray()
+			attr.location.AttribPointer(uint(attr.size), attr.typ,
+				attr.normalized, mb.vstride, uintptr(attr.offset))
+		case attr.name == mbPositionKey:
+			gl.EnableClientState(gl.VERTEX_ARRAY)
+			gl.VertexPointer(attr.size, attr.typ, mb.vstride, uintptr(attr.offset))
+		case attr.name == mbColorKey:
+			gl.EnableClientState(gl.COLOR_ARRAY)
+			gl.ColorPointer(attr.size, attr.typ, mb.vstride, uintptr(attr.offset))
+		case attr.name == mbNormalKey:
+			gl.EnableClientState(gl.NORMAL_ARRAY)
+			gl.NormalPointer(attr.typ, mb.vstride, uintptr(attr.offset))
+		case attr.name == mbTexCoordKey:
+			gl.EnableClientState(gl.TEXTURE_COORD_ARRAY)
+			gl.TexCoordPointer(attr.size, attr.typ, mb.vstride, uintptr(attr.offset))
+		}
+		attr.unbind()
+	}
+
+	// The element array binding is part of the VAO state, so it must stay
+	// bound until the VAO is unbound.
+	ia := mb.find(mbIndexKey)
+	if ia.size > 0 && ia.Len() > 0 {
+		ia.bind()
+	}
+
+	mb.vao.Unbind()
+	if ia.size > 0 && ia.Len() > 0 {
+		ia.unbind()
+	}
+
+	mb.vaoLayout = layout
+}
+
+// renderVAO draws using the vertex array object, recording it first if the
+// attribute layout has changed.
+func (mb *MeshBuffer) renderVAO(mode gl.GLenum, m Mesh) {
+	for _, attr := range mb.attr {
+		if attr.size > 0 && attr.Invalid() {
+			attr.bind()
+			attr.buffer()
+			attr.unbind()
+		}
+	}
+
+	if layout := mb.layout(); mb.vao == 0 || !layoutEqual(layout, mb.vaoLayout) {
+		mb.recordVAO(layout)
+	}
+
+	va := mb.vertexAttr()
+	vs, vc := m[va.name][0], m[va.name][1]
+	ia := mb.find(mbIndexKey)
+	is, ic := m[mbIndexKey][0], m[mbIndexKey][1]
+
+	mb.vao.Bind()
+	if ic > 0 {
+		gl.DrawElements(mode, ic, ia.typ, uintptr(is*ia.stride))
+	} else {
+		gl.DrawArrays(mode, vs, vc)
+	}
+	mb.vao.Unbind()
+}
+
+// vertexAttr returns the attribute which determines the number of vertices:
+// the position attribute, or in RenderShader mode the first attribute
+// other than the indices.
+func (mb *MeshBuffer) vertexAttr() *Attr {
+	if mb.mode != RenderShader {
+		return mb.find(mbPositionKey)
+	}
+	for _, attr := range mb.attr {
+		if attr.name != mbIndexKey && attr.size > 0 {
+			return attr
+		}
+	}
+	return nil
 }
 
 // find finds an attribute with the given name.
@@ -167,6 +630,28 @@ func (mb *MeshBuffer) RenderMesh(index int, mode gl.GLenum) {
 
 // render draws the elements defined by the given mesh object.
 func (mb *MeshBuffer) render(mode gl.GLenum, m Mesh) {
+	if mb.mode == RenderShader && mb.program == 0 {
+		panic("RenderShader mode requires a program; see MeshBuffer.SetProgram")
+	}
+
+	if mb.interleaved {
+		mb.commit()
+	}
+
+	if mb.useVAO {
+		if mb.mode == RenderShader {
+			With(UseProgram{mb.program}, func() { mb.renderVAO(mode, m) })
+		} else {
+			mb.renderVAO(mode, m)
+		}
+		return
+	}
+
+	if mb.mode == RenderShader {
+		mb.renderShader(mode, m)
+		return
+	}
+
 	pa := mb.find(mbPositionKey)
 	ca := mb.find(mbColorKey)
 	na := mb.find(mbNormalKey)
@@ -289,7 +774,7 @@ func (mb *MeshBuffer) renderBuffered(mode gl.GLenum, m Mesh, pa, ca, na, ta, ia
 		if pa.Invalid() {
 			pa.buffer()
 		}
-		gl.VertexPointer(pa.size, pa.typ, 0, uintptr(0))
+		gl.VertexPointer(pa.size, pa.typ, mb.vstride, uintptr(pa.offset))
 		pa.unbind()
 	}
 
@@ -301,7 +786,7 @@ func (mb *MeshBuffer) renderBuffered(mode gl.GLenum, m Mesh, pa, ca, na, ta, ia
 		if ca.Invalid() {
 			ca.buffer()
 		}
-		gl.ColorPointer(ca.size, ca.typ, 0, uintptr(0))
+		gl.ColorPointer(ca.size, ca.typ, mb.vstride, uintptr(ca.offset))
 		ca.unbind()
 	}
 
@@ -313,7 +798,7 @@ func (mb *MeshBuffer) renderBuffered(mode gl.GLenum, m Mesh, pa, ca, na, ta, ia
 		if na.Invalid() {
 			na.buffer()
 		}
-		gl.NormalPointer(na.typ, 0, uintptr(0))
+		gl.NormalPointer(na.typ, mb.vstride, uintptr(na.offset))
 		na.unbind()
 	}
 
@@ -325,7 +810,7 @@ func (mb *MeshBuffer) renderBuffered(mode gl.GLenum, m Mesh, pa, ca, na, ta, ia
 		if ta.Invalid() {
 			ta.buffer()
 		}
-		gl.TexCoordPointer(ta.size, ta.typ, 0, uintptr(0))
+		gl.TexCoordPointer(ta.size, ta.typ, mb.vstride, uintptr(ta.offset))
 		ta.unbind()
 	}
 
@@ -349,15 +834,58 @@ func (mb *MeshBuffer) renderBuffered(mode gl.GLenum, m Mesh, pa, ca, na, ta, ia
 	}
 }
 
+// renderShader binds each attribute used by the program to its generic
+// vertex attribute, and draws with the program.
+func (mb *MeshBuffer) renderShader(mode gl.GLenum, m Mesh) {
+	va := mb.vertexAttr()
+	vs, vc := m[va.name][0], m[va.name][1]
+	ia := mb.find(mbIndexKey)
+	is, ic := m[mbIndexKey][0], m[mbIndexKey][1]
+
+	With(UseProgram{mb.program}, func() {
+		for _, attr := range mb.attr {
+			if attr == ia || attr.size == 0 || attr.location < 0 {
+				continue
+			}
+			if m[attr.name][1] == 0 {
+				continue
+			}
+
+			attr.bind()
+			if attr.Invalid() {
+				attr.buffer()
+			}
+			attr.location.EnableArray()
+			defer attr.location.DisableArray()
+			attr.location.AttribPointer(uint(attr.size), attr.typ,
+				attr.normalized, mb.vstride, uintptr(attr.offset))
+			attr.unbind()
+		}
+
+		if ic > 0 {
+			ia.bind()
+			if ia.Invalid() {
+				ia.buffer()
+			}
+			gl.DrawElements(mode, ic, ia.typ, uintptr(is*ia.stride))
+			ia.unbind()
+		} else {
+			gl.DrawArrays(mode, vs, vc)
+		}
+	})
+}
+
 // Add appends new mesh data to the buffer.
 //
 // The data specified in these lists should match the buffer attributes.
 // We expect to receive lists like []float32, []byte in the same order as
 // the attributes where supplied to NewMeshBuffer.
 //
-// Returns an index into the MeshBuffer.Meshes() list.
+// Returns an index into the MeshBuffer.Meshes() list. Indices change when
+// meshes are removed; use MeshBuffer.Handle to get a stable handle.
 func (mb *MeshBuffer) Add(argv ...interface{}) int {
 	m := make(Mesh)
+	invalid := mb.interleaved && mb.packInvalid()
 
 	for i := 0; i < len(argv) && i < len(mb.attr); i++ {
 		attr := mb.attr[i]
@@ -379,19 +907,146 @@ func (mb *MeshBuffer) Add(argv ...interface{}) int {
 
 	// Update indices if necessary.
 	if index, ok := m[mbIndexKey]; ok {
-		pos, ok := m[mbPositionKey]
+		name := mb.vertexAttr().name
+		pos, ok := m[name]
 		if !ok {
-			panic("Invalid data for attribute: " + mbPositionKey)
+			panic("Invalid data for attribute: " + name)
 		}
 
 		ia := mb.find(mbIndexKey)
-		ia.increment(index[0], float64(pos[0]))
+		ia.increment(index[0]*ia.size, ia.Len(), pos[0])
+	}
+
+	if mb.interleaved {
+		mb.pack(m, invalid)
 	}
 
 	mb.meshes = append(mb.meshes, m)
+	mb.handles = append(mb.handles, mb.next)
+	mb.next++
 	return len(mb.meshes) - 1
 }
 
+// Update replaces the data of the mesh at the given index. The data must
+// match the buffer attributes like in MeshBuffer.Add, and hold exactly as
+// many elements as the mesh it replaces. Indices are relative to the mesh,
+// as they are when adding it.
+func (mb *MeshBuffer) Update(index int, argv ...interface{}) {
+	if index < 0 || index >= len(mb.meshes) {
+		panic("Invalid mesh index")
+	}
+
+	m := mb.meshes[index]
+	invalid := mb.interleaved && mb.packInvalid()
+	indices := false
+
+	// Check all data first, so a mismatch leaves the buffer unchanged.
+	for i := 0; i < len(argv) && i < len(mb.attr); i++ {
+		attr := mb.attr[i]
+
+		if attr.size == 0 {
+			continue
+		}
+
+		if argv[i] == nil {
+			panic("Invalid data for attribute: " + attr.name)
+		}
+
+		if reflect.TypeOf(argv[i]) != reflect.TypeOf(attr.data) {
+			panic("Invalid data type for attribute: " + attr.name)
+		}
+
+		if sliceLen(argv[i]) != m[attr.name][1]*attr.size {
+			panic("Update requires data of the same size for attribute: " + attr.name)
+		}
+	}
+
+	for i := 0; i < len(argv) && i < len(mb.attr); i++ {
+		attr := mb.attr[i]
+
+		if attr.size == 0 {
+			continue
+		}
+
+		attr.replace(m[attr.name][0]*attr.size, argv[i])
+
+		if attr.name == mbIndexKey {
+			indices = true
+		}
+	}
+
+	// Update indices if necessary.
+	if indices {
+		ia := mb.find(mbIndexKey)
+		r := m[mbIndexKey]
+		start := r[0] * ia.size
+		ia.increment(start, start+r[1]*ia.size, m[mb.vertexAttr().name][0])
+	}
+
+	if mb.interleaved {
+		mb.pack(m, invalid)
+	}
+}
+
+// Remove removes the mesh at the given index. The data of the meshes after
+// it is moved down to fill the gap, and their indices decrease by one.
+// Their handles remain valid.
+func (mb *MeshBuffer) Remove(index int) {
+	if index < 0 || index >= len(mb.meshes) {
+		panic("Invalid mesh index")
+	}
+
+	m := mb.meshes[index]
+
+	for _, attr := range mb.attr {
+		r, ok := m[attr.name]
+		if !ok || attr.size == 0 || r[1] == 0 {
+			continue
+		}
+
+		attr.remove(r[0]*attr.size, r[1]*attr.size)
+
+		total := mb.mesh[attr.name][1] - r[1]
+		mb.mesh[attr.name] = [2]int{0, total}
+	}
+
+	// The indices after the removed ones refer to vertices which moved down.
+	// A mesh added without indices has no index range of its own, so they
+	// start where the indices of the meshes before it end.
+	if ia := mb.find(mbIndexKey); ia != nil && ia.size > 0 {
+		start := 0
+		for _, prev := range mb.meshes[:index] {
+			if r, ok := prev[mbIndexKey]; ok {
+				start = r[0] + r[1]
+			}
+		}
+		ia.increment(start*ia.size, ia.Len(), -m[mb.vertexAttr().name][1])
+	}
+
+	for _, next := range mb.meshes[index+1:] {
+		for name, r := range next {
+			next[name] = [2]int{r[0] - m[name][1], r[1]}
+		}
+	}
+
+	mb.meshes = append(mb.meshes[:index], mb.meshes[index+1:]...)
+	mb.handles = append(mb.handles[:index], mb.handles[index+1:]...)
+}
+
+// Handle returns the handle of the mesh at the given index.
+func (mb *MeshBuffer) Handle(index int) MeshHandle { return mb.handles[index] }
+
+// Index returns the current index of the mesh with the given handle,
+// or -1 if it has been removed.
+func (mb *MeshBuffer) Index(h MeshHandle) int {
+	for i, mh := range mb.handles {
+		if mh == h {
+			return i
+		}
+	}
+	return -1
+}
+
 // Mode returns the render mode for this buffer.
 func (mb *MeshBuffer) Mode() RenderMode { return mb.mode }
 
@@ -0,0 +1,410 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+func TestInterleavedLayout(t *testing.T) {
+	// Position: 3 floats, color: 3 ubytes, texcoord: 2 shorts.
+	offsets, stride := interleavedLayout([]int{12, 3, 4})
+
+	want := []int{0, 12, 16}
+	for i := range want {
+		if offsets[i] != want[i] {
+			t.Errorf("offset %d = %d, want %d", i, offsets[i], want[i])
+		}
+	}
+
+	if stride != 20 {
+		t.Errorf("stride = %d, want 20", stride)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	a := NewAttr("a", 2, gl.UNSIGNED_BYTE, gl.STATIC_DRAW)
+	b := NewAttr("b", 1, gl.UNSIGNED_SHORT, gl.STATIC_DRAW)
+	attrs := []*Attr{a, b}
+
+	offsets, stride := interleavedLayout([]int{2, 2})
+	a.offset, b.offset = offsets[0], offsets[1]
+
+	a.append([]uint8{1, 2, 3, 4})
+	b.append([]uint16{0x0605, 0x0807})
+	packed := interleave(nil, stride, 0, 2, attrs)
+
+	a.append([]uint8{9, 10})
+	b.append([]uint16{0x0c0b})
+	packed = interleave(packed, stride, 2, 1, attrs)
+
+	// Little endian shorts.
+	want := []byte{
+		1, 2, 0, 0, 5, 6, 0, 0,
+		3, 4, 0, 0, 7, 8, 0, 0,
+		9, 10, 0, 0, 11, 12, 0, 0,
+	}
+	if !bytes.Equal(packed, want) {
+		t.Errorf("interleave = %v, want %v", packed, want)
+	}
+}
+
+// testMeshBuffer returns a RenderArrays buffer, which requires no GL calls
+// to add, update and remove meshes, holding three triangles.
+func testMeshBuffer() *MeshBuffer {
+	mb := NewMeshBuffer(RenderArrays,
+		NewIndexAttr(1, gl.UNSIGNED_BYTE, gl.STATIC_DRAW),
+		NewPositionAttr(2, gl.FLOAT, gl.STATIC_DRAW),
+	)
+
+	for i := 0; i < 3; i++ {
+		x := float32(i)
+		mb.Add([]uint8{0, 1, 2}, []float32{x, 0, x, 1, x, 2})
+	}
+	return mb
+}
+
+func TestMeshBufferRemove(t *testing.T) {
+	mb := testMeshBuffer()
+	h := mb.Handle(2)
+
+	mb.Remove(1)
+
+	indices := mb.Indices().Data().([]uint8)
+	wantIndices := []uint8{0, 1, 2, 3, 4, 5}
+	if !bytes.Equal(indices, wantIndices) {
+		t.Errorf("indices = %v, want %v", indices, wantIndices)
+	}
+
+	pos := mb.Positions().Data().([]float32)
+	if len(pos) != 12 || pos[0] != 0 || pos[6] != 2 {
+		t.Errorf("positions = %v", pos)
+	}
+
+	if n := len(mb.Meshes()); n != 2 {
+		t.Fatalf("len(Meshes) = %d, want 2", n)
+	}
+
+	m := mb.Meshes()[1]
+	if r := m[mbIndexKey]; r != [2]int{3, 3} {
+		t.Errorf("index range = %v, want [3 3]", r)
+	}
+	if r := m[mbPositionKey]; r != [2]int{3, 3} {
+		t.Errorf("position range = %v, want [3 3]", r)
+	}
+	if r := mb.mesh[mbPositionKey]; r != [2]int{0, 6} {
+		t.Errorf("total position range = %v, want [0 6]", r)
+	}
+
+	if i := mb.Index(h); i != 1 {
+		t.Errorf("Index(h) = %d, want 1", i)
+	}
+
+	mb.Remove(0)
+	indices = mb.Indices().Data().([]uint8)
+	if !bytes.Equal(indices, []uint8{0, 1, 2}) {
+		t.Errorf("indices = %v, want [0 1 2]", indices)
+	}
+	if i := mb.Index(h); i != 0 {
+		t.Errorf("Index(h) = %d, want 0", i)
+	}
+
+	mb.Remove(0)
+	if i := mb.Index(h); i != -1 {
+		t.Errorf("Index(h) = %d after removal, want -1", i)
+	}
+}
+
+func TestMeshBufferUpdate(t *testing.T) {
+	mb := testMeshBuffer()
+
+	mb.Update(1, []uint8{2, 1, 0}, []float32{5, 0, 5, 1, 5, 2})
+
+	indices := mb.Indices().Data().([]uint8)
+	wantIndices := []uint8{0, 1, 2, 5, 4, 3, 6, 7, 8}
+	if !bytes.Equal(indices, wantIndices) {
+		t.Errorf("indices = %v, want %v", indices, wantIndices)
+	}
+
+	pos := mb.Positions().Data().([]float32)
+	if pos[6] != 5 || pos[12] != 2 {
+		t.Errorf("positions = %v", pos)
+	}
+
+	// The positions do not match, so the indices must not change either.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Update with different size did not panic")
+			}
+		}()
+		mb.Update(0, []uint8{2, 1, 0}, []float32{0, 0, 0, 1})
+	}()
+
+	indices = mb.Indices().Data().([]uint8)
+	if !bytes.Equal(indices, wantIndices) {
+		t.Errorf("indices = %v after failed Update, want %v", indices, wantIndices)
+	}
+}
+
+// A mesh added without indices, because the index attribute comes after the
+// vertex data, still moves the indices of the meshes after it when removed.
+func TestMeshBufferRemoveWithoutIndices(t *testing.T) {
+	mb := NewMeshBuffer(RenderArrays,
+		NewPositionAttr(2, gl.FLOAT, gl.STATIC_DRAW),
+		NewIndexAttr(1, gl.UNSIGNED_BYTE, gl.STATIC_DRAW),
+	)
+	mb.Add([]float32{0, 0, 0, 1, 0, 2}, []uint8{0, 1, 2})
+	mb.Add([]float32{1, 0, 1, 1, 1, 2})
+	mb.Add([]float32{2, 0, 2, 1, 2, 2}, []uint8{2, 1, 0})
+
+	mb.Remove(1)
+
+	indices := mb.Indices().Data().([]uint8)
+	wantIndices := []uint8{0, 1, 2, 5, 4, 3}
+	if !bytes.Equal(indices, wantIndices) {
+		t.Errorf("indices = %v, want %v", indices, wantIndices)
+	}
+	if r := mb.Meshes()[1][mbIndexKey]; r != [2]int{3, 3} {
+		t.Errorf("index range = %v, want [3 3]", r)
+	}
+}
+
+func TestMeshBufferUpdateType(t *testing.T) {
+	mb := testMeshBuffer()
+
+	// The indices are valid, but the positions have the wrong type, so
+	// neither may change.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Update with wrong type did not panic")
+			}
+		}()
+		mb.Update(1, []uint8{2, 1, 0}, []float64{5, 0, 5, 1, 5, 2})
+	}()
+
+	indices := mb.Indices().Data().([]uint8)
+	wantIndices := []uint8{0, 1, 2, 3, 4, 5, 6, 7, 8}
+	if !bytes.Equal(indices, wantIndices) {
+		t.Errorf("indices = %v after failed Update, want %v", indices, wantIndices)
+	}
+	if pos := mb.Positions().Data().([]float32); pos[6] != 1 {
+		t.Errorf("positions = %v after failed Update", pos)
+	}
+}
+
+// testInterleavedBuffer returns a RenderArrays buffer with the interleaved
+// layout, but without the VBO of SetInterleaved, so that packing can be
+// tested without a GL context.
+func testInterleavedBuffer() *MeshBuffer {
+	mb := NewMeshBuffer(RenderArrays,
+		NewIndexAttr(1, gl.UNSIGNED_BYTE, gl.STATIC_DRAW),
+		NewPositionAttr(2, gl.FLOAT, gl.STATIC_DRAW),
+		NewColorAttr(3, gl.UNSIGNED_BYTE, gl.STATIC_DRAW),
+	)
+	mb.setInterleaved(true)
+	mb.repack() // Validates the attributes, so Add packs incrementally.
+	return mb
+}
+
+// addTriangle adds a triangle with the x coordinate and red value c.
+func addTriangle(mb *MeshBuffer, c uint8) int {
+	x := float32(c)
+	return mb.Add([]uint8{0, 1, 2},
+		[]float32{x, 0, x, 1, x, 2},
+		[]uint8{c, 0, 0, c, 0, 0, c, 0, 0})
+}
+
+// checkPacked verifies that the interleaved data holds the attributes.
+func checkPacked(t *testing.T, mb *MeshBuffer, vertices int) {
+	if mb.packInvalid() {
+		t.Fatal("attributes invalid after packing")
+	}
+	want := interleave(nil, mb.vstride, 0, vertices, mb.packedAttrs())
+	if !bytes.Equal(mb.packed, want) {
+		t.Errorf("packed = %v, want %v", mb.packed, want)
+	}
+}
+
+func TestMeshBufferInterleavedAdd(t *testing.T) {
+	mb := testInterleavedBuffer()
+	if mb.vstride != 12 || mb.Colors().Offset() != 8 {
+		t.Fatalf("stride %d, color offset %d; want 12, 8",
+			mb.vstride, mb.Colors().Offset())
+	}
+
+	addTriangle(mb, 1)
+	addTriangle(mb, 2)
+	checkPacked(t, mb, 6)
+
+	// A mesh without vertices leaves the packed data alone.
+	mb.Add()
+	addTriangle(mb, 3)
+	checkPacked(t, mb, 9)
+	if c := mb.packed[4*mb.vstride+8]; c != 2 {
+		t.Errorf("red of vertex 4 = %d, want 2", c)
+	}
+
+	// Modified data is repacked entirely.
+	mb.Positions().Data().([]float32)[0] = 7
+	mb.Positions().Invalidate()
+	addTriangle(mb, 4)
+	if !mb.packInvalid() {
+		t.Fatal("Add packed over invalid data")
+	}
+	mb.repack()
+	checkPacked(t, mb, 12)
+}
+
+func TestMeshBufferInterleavedCounts(t *testing.T) {
+	mb := testInterleavedBuffer()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Add with unequal vertex counts did not panic")
+		}
+	}()
+	mb.Add([]uint8{0, 1, 2}, []float32{0, 0, 0, 1, 0, 2}, []uint8{1, 0, 0})
+}
+
+func TestMeshBufferInterleavedUpdate(t *testing.T) {
+	mb := testInterleavedBuffer()
+	for c := uint8(1); c <= 3; c++ {
+		addTriangle(mb, c)
+	}
+
+	mb.Update(1, []uint8{2, 1, 0},
+		[]float32{5, 0, 5, 1, 5, 2},
+		[]uint8{5, 0, 0, 5, 0, 0, 5, 0, 0})
+	checkPacked(t, mb, 9)
+
+	if c := mb.packed[7*mb.vstride+8]; c != 3 {
+		t.Errorf("red of vertex 7 = %d, want 3", c)
+	}
+}
+
+func TestMeshBufferInterleavedRemove(t *testing.T) {
+	mb := testInterleavedBuffer()
+	for c := uint8(1); c <= 3; c++ {
+		addTriangle(mb, c)
+	}
+
+	mb.Remove(0)
+	mb.repack()
+	checkPacked(t, mb, 6)
+
+	if c := mb.packed[0*mb.vstride+8]; c != 2 {
+		t.Errorf("red of vertex 0 = %d, want 2", c)
+	}
+	if n := len(mb.packed); n != 6*mb.vstride {
+		t.Errorf("packed %d bytes, want %d", n, 6*mb.vstride)
+	}
+}
+
+func TestMeshBufferShaderVertexAttr(t *testing.T) {
+	// The first attribute other than the indices with a size counts the
+	// vertices, whatever its name.
+	mb := &MeshBuffer{mode: RenderShader, attr: []*Attr{
+		NewIndexAttr(1, gl.UNSIGNED_SHORT, gl.STATIC_DRAW),
+		NewAttr("unused", 0, 0, 0),
+		NewAttr("vertex", 3, gl.FLOAT, gl.STATIC_DRAW),
+		NewAttr("uv", 2, gl.FLOAT, gl.STATIC_DRAW),
+	}}
+	if a := mb.vertexAttr(); a == nil || a.Name() != "vertex" {
+		t.Errorf("vertexAttr = %v, want vertex", a)
+	}
+
+	mb.mode = RenderArrays
+	if a := mb.vertexAttr(); a != nil {
+		t.Errorf("vertexAttr = %v without position attribute, want nil", a.Name())
+	}
+
+	mb.mode = RenderShader
+	mb.attr[2].location = 0
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Render without program did not panic")
+			}
+		}()
+		mb.Render(gl.TRIANGLES)
+	}()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RenderShader buffer without vertex attribute did not panic")
+		}
+	}()
+	NewMeshBuffer(RenderShader, NewIndexAttr(1, gl.UNSIGNED_SHORT, gl.STATIC_DRAW))
+}
+
+func TestMeshBufferLayoutChanges(t *testing.T) {
+	mb := testMeshBuffer()
+	pos := mb.Positions()
+	recorded := mb.layout()
+
+	if !layoutEqual(recorded, mb.layout()) {
+		t.Fatal("unchanged layout differs")
+	}
+
+	changes := []struct {
+		Name   string
+		Change func()
+	}{
+		{"location", func() { pos.location = 3 }},
+		{"type", func() { pos.typ = gl.DOUBLE }},
+		{"size", func() { pos.size = 3 }},
+		{"vbo of a re-created attribute", func() { pos.vbo = 7 }},
+		{"offset", func() { pos.offset = 8 }},
+		{"normalized", func() { pos.normalized = true }},
+		{"emptied", func() { pos.data = []float32{} }},
+	}
+
+	for _, c := range changes {
+		saved := *pos
+		c.Change()
+		if layoutEqual(recorded, mb.layout()) {
+			t.Errorf("%s change not detected", c.Name)
+		}
+		*pos = saved
+	}
+}
+
+func TestMeshBufferVertexArrayFallback(t *testing.T) {
+	// RenderArrays does not use VBOs, so has no layout to record.
+	mb := testMeshBuffer()
+	if mb.SetVertexArray(true) || mb.VertexArray() {
+		t.Error("vertex array enabled in RenderArrays mode")
+	}
+}
+
+// Without ARB_vertex_array_object, buffers render without recording a VAO.
+func TestMeshBufferVertexArrayUnsupported(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		mb := NewMeshBuffer(RenderBuffered,
+			NewPositionAttr(2, gl.FLOAT, gl.STATIC_DRAW),
+		)
+		defer mb.Release()
+		mb.Add([]float32{0, 0, 1, 0, 0, 1})
+
+		mb.vaoSupported = func() bool { return false }
+		if mb.SetVertexArray(true) || mb.VertexArray() {
+			t.Fatal("vertex array enabled without support")
+		}
+
+		mb.Render(gl.TRIANGLES)
+		if mb.vao != 0 {
+			t.Errorf("Render recorded vertex array %v", mb.vao)
+		}
+		if vao := getBinding(gl.VERTEX_ARRAY_BINDING); vao != 0 {
+			t.Errorf("vertex array %d left bound", vao)
+		}
+	}, func() {})
+}
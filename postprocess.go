@@ -0,0 +1,165 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"github.com/go-gl/gl"
+)
+
+// A PingPong owns two textures of equal size, for a sequence of passes which
+// each read the result of the previous one.
+// Example:
+//     pp := NewPingPong(w, h, gl.RGBA)
+//     for i := 0; i < 4; i++ {
+//         pp.Pass(func(src *Texture) { .. draw using src .. })
+//     }
+//     .. use pp.Front ..
+type PingPong struct {
+	Front *Texture // The most recent result.
+	Back  *Texture // The target of the next pass.
+}
+
+// NewPingPong creates the two textures with the given internal format.
+func NewPingPong(w, h int, internalformat int) *PingPong {
+	p := &PingPong{NewTexture(w, h), NewTexture(w, h)}
+	p.Front.InitFormat(internalformat)
+	p.Back.InitFormat(internalformat)
+	return p
+}
+
+// Swap exchanges the front and back textures.
+func (p *PingPong) Swap() {
+	p.Front, p.Back = p.Back, p.Front
+}
+
+// Pass calls fn with the front texture while rendering into the back texture,
+// then swaps them.
+func (p *PingPong) Pass(fn func(src *Texture)) {
+	src := p.Front
+	With(&Framebuffer{Texture: p.Back}, func() { fn(src) })
+	p.Swap()
+}
+
+// Release deletes the textures.
+func (p *PingPong) Release() {
+	p.Front.Delete()
+	p.Back.Delete()
+}
+
+// The vertex shader of full-screen effects. It passes the texture
+// co-ordinates of the quad drawn by DrawFullscreen in the varying texcoord.
+const FullscreenVertexShader = `#version 120
+varying vec2 texcoord;
+void main() {
+	gl_Position = ftransform();
+	texcoord = gl_MultiTexCoord0.st;
+}
+`
+
+// DrawFullscreen draws a quad covering the viewport, with texture
+// co-ordinates running from 0 to 1.
+func DrawFullscreen() {
+	With(WindowCoords{Invert: true}, func() {
+		w, h := GetViewportWH()
+		DrawQuadi(0, 0, w, h)
+	})
+}
+
+// An Effect draws a full-screen image derived from a source texture into the
+// current framebuffer.
+type Effect interface {
+	Apply(src *Texture)
+}
+
+// A ShaderEffect draws src with a fragment shader. The shader reads the
+// varying texcoord, and may declare the uniforms:
+//     uniform sampler2D source; // src
+//     uniform vec2 texelSize;   // 1 / the size of src
+type ShaderEffect struct {
+	Program gl.Program
+
+	// Called with the program in use before drawing, to set further
+	// uniforms. May be nil.
+	Uniforms func(p gl.Program)
+}
+
+// NewShaderEffect links fragment with FullscreenVertexShader.
+func NewShaderEffect(fragment string, uniforms func(p gl.Program)) *ShaderEffect {
+	return &ShaderEffect{
+		Program: NewProgram(
+			Shader{gl.VERTEX_SHADER, FullscreenVertexShader},
+			Shader{gl.FRAGMENT_SHADER, fragment}),
+		Uniforms: uniforms,
+	}
+}
+
+func (e *ShaderEffect) Apply(src *Texture) {
+	With(Compound(UseProgram{e.Program}, src), func() {
+		e.Program.GetUniformLocation("source").Uniform1i(0)
+		e.Program.GetUniformLocation("texelSize").Uniform2f(
+			1/float32(src.W), 1/float32(src.H))
+		if e.Uniforms != nil {
+			e.Uniforms(e.Program)
+		}
+		DrawFullscreen()
+	})
+}
+
+// Release deletes the program.
+func (e *ShaderEffect) Release() {
+	e.Program.Delete()
+}
+
+// A PostProcess chain applies a list of effects in order, each reading the
+// output of the previous one. Intermediate results are stored in a PingPong.
+// Example:
+//     chain := NewPostProcess(w, h, gl.RGBA, blur, vignette)
+//     With(&Framebuffer{Texture: scene}, drawScene)
+//     chain.Draw(scene) // To the window
+type PostProcess struct {
+	Effects []Effect
+	buffers *PingPong
+}
+
+// NewPostProcess creates a chain of effects whose intermediate textures have
+// the given size and internal format.
+func NewPostProcess(w, h int, internalformat int, effects ...Effect) *PostProcess {
+	return &PostProcess{
+		Effects: effects,
+		buffers: NewPingPong(w, h, internalformat),
+	}
+}
+
+func (c *PostProcess) run(src *Texture, effects []Effect) *Texture {
+	for _, e := range effects {
+		// The first effect reads src rather than the front buffer.
+		With(&Framebuffer{Texture: c.buffers.Back}, func() { e.Apply(src) })
+		c.buffers.Swap()
+		src = c.buffers.Front
+	}
+	return src
+}
+
+// Run applies the effects to src and returns the texture holding the result.
+// It remains valid until the chain is next used.
+func (c *PostProcess) Run(src *Texture) *Texture {
+	return c.run(src, c.Effects)
+}
+
+// Draw applies the effects to src, the last one drawing directly into the
+// current framebuffer, such as the window.
+func (c *PostProcess) Draw(src *Texture) {
+	if len(c.Effects) == 0 {
+		With(src, DrawFullscreen)
+		return
+	}
+	n := len(c.Effects) - 1
+	c.Effects[n].Apply(c.run(src, c.Effects[:n]))
+}
+
+// Release deletes the intermediate textures. The effects are not released.
+func (c *PostProcess) Release() {
+	c.buffers.Release()
+}
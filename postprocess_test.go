@@ -0,0 +1,68 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+func TestPingPongSwap(t *testing.T) {
+	a, b := &Texture{gl.Texture(1), 4, 4}, &Texture{gl.Texture(2), 4, 4}
+	p := &PingPong{Front: a, Back: b}
+
+	for i := 0; i < 5; i++ {
+		p.Swap()
+		want := [2]*Texture{b, a}
+		if i%2 == 1 {
+			want = [2]*Texture{a, b}
+		}
+		if have := [2]*Texture{p.Front, p.Back}; have != want {
+			t.Fatalf("swap %d: Want %v, Have %v", i+1, want, have)
+		}
+	}
+}
+
+// recordEffect records the textures it is applied to.
+type recordEffect struct{ src *[]gl.Texture }
+
+func (e recordEffect) Apply(src *Texture) { *e.src = append(*e.src, src.Texture) }
+
+// Each pass reads the result of the previous one, which alternates between
+// the two textures, and the result ends up in Front.
+func TestPingPongPasses(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		var src []gl.Texture
+		e := recordEffect{&src}
+
+		scene := NewTexture(4, 4)
+		scene.InitFormat(gl.RGBA)
+		defer scene.Delete()
+
+		chain := NewPostProcess(4, 4, gl.RGBA, e, e, e)
+		defer chain.Release()
+		a, b := chain.buffers.Front.Texture, chain.buffers.Back.Texture
+
+		result := chain.Run(scene)
+
+		want := []gl.Texture{scene.Texture, b, a}
+		if !reflect.DeepEqual(src, want) {
+			t.Fatalf("passes: Want %v, Have %v", want, src)
+		}
+		if result != chain.buffers.Front || result.Texture != b {
+			t.Errorf("Run returned %v, want front texture %v", result.Texture, b)
+		}
+
+		src = nil
+		pp := chain.buffers
+		pp.Pass(func(tex *Texture) { e.Apply(tex) })
+		if len(src) != 1 || src[0] != b || pp.Front.Texture != a {
+			t.Errorf("Pass: src %v, front %v", src, pp.Front.Texture)
+		}
+	}, func() {})
+}
@@ -0,0 +1,348 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-gl/gl"
+)
+
+// queryRing cycles through a number of query objects so that results can be
+// collected a few frames after they were issued, without stalling the
+// pipeline waiting for the most recent one.
+type queryRing struct {
+	target  gl.GLenum
+	queries []gl.Query
+	pending []bool // Has the query been issued, but not collected?
+	next    int    // Index of the query used by the next begin.
+	oldest  int    // Index of the oldest pending query.
+	active  bool   // Between begin and end?
+
+	result  uint64   // Most recently collected result.
+	valid   bool     // Has any result been collected?
+	results []uint64 // Collected results not yet returned by poll.
+}
+
+// The number of collected results a queryRing keeps for poll. If poll is not
+// called, the oldest are dropped.
+const maxQueryResults = 256
+
+func newQueryRing(target gl.GLenum, n int) *queryRing {
+	if n < 1 {
+		n = 1
+	}
+	return &queryRing{
+		target:  target,
+		queries: make([]gl.Query, n),
+		pending: make([]bool, n),
+	}
+}
+
+func (r *queryRing) begin() {
+	if r.active {
+		log.Panic("glh: query begun twice without end")
+	}
+
+	q := &r.queries[r.next]
+	if *q == 0 {
+		*q = gl.GenQuery()
+	}
+
+	// The ring is full. Collecting the oldest result blocks until it
+	// is available; use a larger ring to avoid this.
+	if r.full() {
+		r.collect(true)
+	}
+
+	q.Begin(r.target)
+	r.active = true
+}
+
+func (r *queryRing) end() {
+	gl.EndQuery(r.target)
+	r.active = false
+	r.push()
+}
+
+// full reports whether the query for the next begin is still pending.
+func (r *queryRing) full() bool { return r.pending[r.next] }
+
+// push marks the query for the next begin, which has just ended, pending and
+// advances the ring.
+func (r *queryRing) push() {
+	if r.full() {
+		log.Panic("glh: query ring overflow")
+	}
+	r.pending[r.next] = true
+	r.next = (r.next + 1) % len(r.queries)
+}
+
+// front returns the oldest pending query, or false if there is none.
+func (r *queryRing) front() (gl.Query, bool) {
+	return r.queries[r.oldest], r.pending[r.oldest]
+}
+
+// pop records result as the result of the oldest pending query and retires
+// it.
+func (r *queryRing) pop(result uint64) {
+	if !r.pending[r.oldest] {
+		log.Panic("glh: query ring underflow")
+	}
+	r.result, r.valid = result, true
+	if len(r.results) == maxQueryResults {
+		r.results = append(r.results[:0], r.results[1:]...)
+	}
+	r.results = append(r.results, result)
+
+	r.pending[r.oldest] = false
+	r.oldest = (r.oldest + 1) % len(r.queries)
+}
+
+// poll collects all results which are available, without blocking.
+// Returns the results collected since the last poll, oldest first,
+// including those begin had to wait for, up to maxQueryResults.
+func (r *queryRing) poll() []uint64 {
+	for r.collect(false) {
+	}
+	results := r.results
+	r.results = nil
+	return results
+}
+
+// collect collects the result of the oldest pending query. If wait is false
+// and the result is not available yet, it returns false.
+func (r *queryRing) collect(wait bool) bool {
+	q, ok := r.front()
+	if !ok {
+		return false
+	}
+
+	if !wait {
+		var available [1]int32
+		q.GetObjectiv(gl.QUERY_RESULT_AVAILABLE, available[:])
+		if available[0] == 0 {
+			return false
+		}
+	}
+
+	var result [1]uint64
+	q.GetObjectui64v(gl.QUERY_RESULT, result[:])
+	r.pop(result[0])
+	return true
+}
+
+func (r *queryRing) release() {
+	for i, q := range r.queries {
+		if q != 0 {
+			q.Delete()
+		}
+		r.queries[i] = 0
+		r.pending[i] = false
+	}
+	r.next, r.oldest, r.active, r.valid = 0, 0, false, false
+	r.results = nil
+}
+
+// A TimerQuery measures the GPU time spent on the commands issued during the
+// context, using gl.TIME_ELAPSED queries. Timer queries cannot be nested.
+// Example:
+//     tq := NewTimerQuery(3)
+//     With(tq, func() { .. render .. })
+//     if d, ok := tq.Result(); ok { .. }
+type TimerQuery struct{ *queryRing }
+
+// NewTimerQuery creates a timer query which cycles through n query objects.
+// n should cover the number of frames the GPU lags behind, typically 3.
+func NewTimerQuery(n int) *TimerQuery {
+	return &TimerQuery{newQueryRing(gl.TIME_ELAPSED, n)}
+}
+
+func (t *TimerQuery) Enter() { t.begin() }
+func (t *TimerQuery) Exit()  { t.end() }
+
+// Poll collects all available results without blocking, oldest first. Call
+// it every frame; results not polled are kept only up to a limit.
+func (t *TimerQuery) Poll() []time.Duration {
+	results := t.poll()
+	durations := make([]time.Duration, len(results))
+	for i, r := range results {
+		durations[i] = time.Duration(r)
+	}
+	return durations
+}
+
+// Result polls for new results and returns the most recent measurement.
+// It returns false if no measurement has completed yet.
+func (t *TimerQuery) Result() (time.Duration, bool) {
+	t.poll()
+	return time.Duration(t.result), t.valid
+}
+
+// Release deletes the query objects.
+func (t *TimerQuery) Release() { t.release() }
+
+// An OcclusionQuery counts the samples which pass the depth test during the
+// context. Target is either gl.SAMPLES_PASSED, which counts the samples, or
+// gl.ANY_SAMPLES_PASSED, which only reports whether any did.
+type OcclusionQuery struct{ *queryRing }
+
+// NewOcclusionQuery creates an occlusion query of the given target which
+// cycles through n query objects.
+func NewOcclusionQuery(target gl.GLenum, n int) *OcclusionQuery {
+	switch target {
+	case gl.SAMPLES_PASSED, gl.ANY_SAMPLES_PASSED:
+	default:
+		log.Panic("glh: invalid occlusion query target")
+	}
+	return &OcclusionQuery{newQueryRing(target, n)}
+}
+
+func (o *OcclusionQuery) Enter() { o.begin() }
+func (o *OcclusionQuery) Exit()  { o.end() }
+
+// Poll collects all available results without blocking, oldest first. Call
+// it every frame; results not polled are kept only up to a limit.
+func (o *OcclusionQuery) Poll() []uint64 { return o.poll() }
+
+// Samples polls for new results and returns the most recent sample count.
+// For gl.ANY_SAMPLES_PASSED queries this is 0 or 1.
+// It returns false if no query has completed yet.
+func (o *OcclusionQuery) Samples() (uint64, bool) {
+	o.poll()
+	return o.result, o.valid
+}
+
+// Visible polls for new results and reports whether any samples passed in
+// the most recent completed query. Before any query completes it reports
+// true, so that objects are drawn rather than culled.
+func (o *OcclusionQuery) Visible() bool {
+	n, ok := o.Samples()
+	return !ok || n > 0
+}
+
+// Release deletes the query objects.
+func (o *OcclusionQuery) Release() { o.release() }
+
+// ProfileStats holds the aggregated timings of a single profiler scope.
+type ProfileStats struct {
+	Name  string
+	Count int           // Number of collected measurements.
+	Last  time.Duration // Most recent measurement.
+	Min   time.Duration
+	Max   time.Duration
+	Total time.Duration
+}
+
+// Mean returns the average measurement.
+func (s *ProfileStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+func (s *ProfileStats) add(d time.Duration) {
+	if s.Count == 0 || d < s.Min {
+		s.Min = d
+	}
+	if d > s.Max {
+		s.Max = d
+	}
+	s.Count++
+	s.Last = d
+	s.Total += d
+}
+
+// A Profiler aggregates the GPU time of named render passes.
+// Example:
+//     p := NewProfiler(3)
+//     for { // Each frame
+//         With(p.Scope("shadows"), drawShadows)
+//         With(p.Scope("scene"), drawScene)
+//         p.Frame()
+//     }
+//     fmt.Print(p.Report())
+//
+// Scopes are timer queries, so they cannot be nested.
+type Profiler struct {
+	n      int
+	order  []string
+	scopes map[string]*TimerQuery
+	stats  map[string]*ProfileStats
+}
+
+// NewProfiler creates a profiler whose scopes cycle through n query objects.
+func NewProfiler(n int) *Profiler {
+	return &Profiler{
+		n:      n,
+		scopes: make(map[string]*TimerQuery),
+		stats:  make(map[string]*ProfileStats),
+	}
+}
+
+// Scope returns the timer query context for the named scope.
+func (p *Profiler) Scope(name string) Context {
+	tq, ok := p.scopes[name]
+	if !ok {
+		tq = NewTimerQuery(p.n)
+		p.scopes[name] = tq
+		p.stats[name] = &ProfileStats{Name: name}
+		p.order = append(p.order, name)
+	}
+	return Named(name, tq)
+}
+
+// Frame collects the available results of all scopes. Call it once per frame.
+func (p *Profiler) Frame() {
+	for name, tq := range p.scopes {
+		s := p.stats[name]
+		for _, d := range tq.Poll() {
+			s.add(d)
+		}
+	}
+}
+
+// Stats returns the statistics of all scopes, in the order they were first
+// used.
+func (p *Profiler) Stats() []ProfileStats {
+	result := make([]ProfileStats, len(p.order))
+	for i, name := range p.order {
+		result[i] = *p.stats[name]
+	}
+	return result
+}
+
+// Reset clears the collected statistics.
+func (p *Profiler) Reset() {
+	for name := range p.stats {
+		p.stats[name] = &ProfileStats{Name: name}
+	}
+}
+
+// Report returns a table of the collected statistics.
+func (p *Profiler) Report() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%-20s %8s %12s %12s %12s %12s\n",
+		"scope", "count", "last", "mean", "min", "max")
+	for _, s := range p.Stats() {
+		fmt.Fprintf(&buf, "%-20s %8d %12v %12v %12v %12v\n",
+			s.Name, s.Count, s.Last, s.Mean(), s.Min, s.Max)
+	}
+	return buf.String()
+}
+
+// Release deletes the query objects of all scopes.
+func (p *Profiler) Release() {
+	for _, tq := range p.scopes {
+		tq.Release()
+	}
+	p.scopes = make(map[string]*TimerQuery)
+	p.stats = make(map[string]*ProfileStats)
+	p.order = nil
+}
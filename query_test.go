@@ -0,0 +1,152 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gl/gl"
+)
+
+func TestQueryRing(t *testing.T) {
+	r := newQueryRing(gl.TIME_ELAPSED, 3)
+	for i := range r.queries {
+		r.queries[i] = gl.Query(10 + i)
+	}
+
+	if _, ok := r.front(); ok {
+		t.Fatal("new ring has a pending query")
+	}
+
+	// Queries 0 to 6 through three slots, collecting whenever the ring is
+	// full as begin does. Results are 100 plus the query number.
+	collected := 0
+	for i := 0; i < 7; i++ {
+		if r.full() {
+			q, _ := r.front()
+			if want := gl.Query(10 + i%3); q != want {
+				t.Fatalf("query %d: full ring collects %v, want %v", i, q, want)
+			}
+			r.pop(uint64(100 + collected))
+			collected++
+		}
+		if r.next != i%3 {
+			t.Fatalf("query %d: Want slot %d, Have %d", i, i%3, r.next)
+		}
+		r.push()
+	}
+	if collected != 4 {
+		t.Errorf("collected %d results when full, want 4", collected)
+	}
+
+	// Draining the ring the way poll would, results come out oldest first,
+	// including those collected because the ring was full.
+	for ; collected < 7; collected++ {
+		r.pop(uint64(100 + collected))
+	}
+	want := []uint64{100, 101, 102, 103, 104, 105, 106}
+	if res := r.poll(); !reflect.DeepEqual(res, want) {
+		t.Errorf("poll: Want %v, Have %v", want, res)
+	}
+	if res := r.poll(); len(res) != 0 {
+		t.Errorf("second poll returned %v", res)
+	}
+	if r.result != 106 || !r.valid {
+		t.Errorf("result: Want 106, Have %v (valid %v)", r.result, r.valid)
+	}
+	if r.next != 1 || r.oldest != 1 {
+		t.Errorf("indices: Want 1, 1, Have %d, %d", r.next, r.oldest)
+	}
+}
+
+func TestQueryRingResultLimit(t *testing.T) {
+	r := newQueryRing(gl.TIME_ELAPSED, 1)
+	for i := 0; i < maxQueryResults+10; i++ {
+		r.push()
+		r.pop(uint64(i))
+	}
+	res := r.poll()
+	if len(res) != maxQueryResults || res[0] != 10 ||
+		res[len(res)-1] != maxQueryResults+9 {
+		t.Errorf("Want the latest %d results, Have %d from %d",
+			maxQueryResults, len(res), res[0])
+	}
+}
+
+func TestQueryRingPanics(t *testing.T) {
+	tests := []struct {
+		Name string
+		Fn   func(r *queryRing)
+	}{
+		{"underflow", func(r *queryRing) { r.pop(0) }},
+		{"overflow", func(r *queryRing) { r.push(); r.push() }},
+		{"begin twice", func(r *queryRing) { r.active = true; r.begin() }},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", test.Name)
+				}
+			}()
+			test.Fn(newQueryRing(gl.TIME_ELAPSED, 1))
+		}()
+	}
+}
+
+// timings feeds durations into the timer query of a profiler scope, as if
+// they had been measured.
+func timings(p *Profiler, name string, ds ...time.Duration) {
+	p.Scope(name)
+	r := p.scopes[name].queryRing
+	for _, d := range ds {
+		r.push()
+		r.pop(uint64(d))
+	}
+}
+
+func TestProfilerReport(t *testing.T) {
+	p := NewProfiler(1)
+	timings(p, "shadows", 3*time.Millisecond, time.Millisecond)
+	timings(p, "scene", 10*time.Millisecond)
+	p.Frame()
+	timings(p, "shadows", 2*time.Millisecond)
+	p.Frame()
+
+	want := []ProfileStats{
+		{"shadows", 3, 2 * time.Millisecond, time.Millisecond,
+			3 * time.Millisecond, 6 * time.Millisecond},
+		{"scene", 1, 10 * time.Millisecond, 10 * time.Millisecond,
+			10 * time.Millisecond, 10 * time.Millisecond},
+	}
+	stats := p.Stats()
+	if !reflect.DeepEqual(stats, want) {
+		t.Fatalf("Want %+v, Have %+v", want, stats)
+	}
+	if m := stats[0].Mean(); m != 2*time.Millisecond {
+		t.Errorf("mean: Want 2ms, Have %v", m)
+	}
+
+	lines := strings.Split(strings.TrimSpace(p.Report()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("report has %d lines:\n%s", len(lines), p.Report())
+	}
+	if f := strings.Fields(lines[1]); f[0] != "shadows" || f[1] != "3" ||
+		f[2] != "2ms" || f[3] != "2ms" || f[4] != "1ms" || f[5] != "3ms" {
+		t.Errorf("shadows row: %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); f[0] != "scene" || f[1] != "1" {
+		t.Errorf("scene row: %q", lines[2])
+	}
+
+	p.Reset()
+	if s := p.Stats(); s[0].Count != 0 || s[1].Count != 0 {
+		t.Errorf("Reset kept %+v", s)
+	}
+}
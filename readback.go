@@ -0,0 +1,199 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"image"
+	"log"
+
+	"github.com/go-gl/gl"
+)
+
+// A Readback reads pixels without stalling the pipeline, unlike CaptureRGBA
+// and Texture.AsImage. Each read is copied into one of a ring of pixel buffer
+// objects and fenced; Poll delivers the images of the reads which have
+// completed, typically a few frames later.
+// Example:
+//     rb := NewReadback(w, h, 3)
+//     for { // Each frame
+//         .. render ..
+//         rb.Read(0, 0, func(im *image.RGBA) { .. encode im .. })
+//         rb.Poll()
+//     }
+//
+// As with CaptureRGBA, the rows of the images are ordered bottom to top.
+type Readback struct {
+	W, H int
+
+	slots  []readbackSlot
+	next   int // Index of the slot used by the next read.
+	oldest int // Index of the oldest pending slot.
+}
+
+type readbackSlot struct {
+	pbo     gl.Buffer
+	fence   gl.Sync
+	pending bool
+	deliver func(*image.RGBA)
+}
+
+// NewReadback creates a readback of w by h pixels which cycles through n
+// pixel buffer objects. n should cover the number of frames the GPU lags
+// behind, typically 3.
+func NewReadback(w, h, n int) *Readback {
+	if n < 1 {
+		n = 1
+	}
+	return &Readback{W: w, H: h, slots: make([]readbackSlot, n)}
+}
+
+// ReadbackChan returns a function which sends the delivered images to c. The
+// images are sent from Poll, so c should be buffered.
+func ReadbackChan(c chan<- *image.RGBA) func(*image.RGBA) {
+	return func(im *image.RGBA) { c <- im }
+}
+
+// Read issues a read of the pixels at x, y of the current read framebuffer.
+// Fn is called with the image by a later Poll or Flush.
+func (r *Readback) Read(x, y int, fn func(*image.RGBA)) {
+	r.issue(fn, func() {
+		gl.ReadPixels(x, y, r.W, r.H, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	})
+}
+
+// ReadTexture issues a read of the base level of t, which must be W by H.
+// Fn is called with the image by a later Poll or Flush.
+func (r *Readback) ReadTexture(t *Texture, fn func(*image.RGBA)) {
+	if t.W != r.W || t.H != r.H {
+		log.Panicf("Readback: texture is %dx%d, expected %dx%d",
+			t.W, t.H, r.W, r.H)
+	}
+	r.issue(fn, func() {
+		With(t, func() {
+			gl.GetTexImage(gl.TEXTURE_2D, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+		})
+	})
+}
+
+// issue runs read, which reads into the bound pixel pack buffer, on the next
+// slot of the ring.
+func (r *Readback) issue(fn func(*image.RGBA), read func()) {
+	// The ring is full. Collecting the oldest result blocks until it
+	// is available; use a larger ring to avoid this.
+	if r.slots[r.next].pending {
+		r.collect(true)
+	}
+
+	s := &r.slots[r.next]
+	if s.pbo == 0 {
+		s.pbo = gl.GenBuffer()
+		With(BindBuffer{gl.PIXEL_PACK_BUFFER, s.pbo}, func() {
+			gl.BufferData(gl.PIXEL_PACK_BUFFER, r.W*r.H*4, nil, gl.STREAM_READ)
+		})
+	}
+
+	With(BindBuffer{gl.PIXEL_PACK_BUFFER, s.pbo}, read)
+	s.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+	r.push(fn)
+}
+
+// push marks the next slot, whose read has been issued and fenced, pending
+// delivery to fn and advances the ring.
+func (r *Readback) push(fn func(*image.RGBA)) {
+	s := &r.slots[r.next]
+	if s.pending {
+		log.Panic("Readback: ring overflow")
+	}
+	s.pending, s.deliver = true, fn
+	r.next = (r.next + 1) % len(r.slots)
+}
+
+// front returns the oldest pending slot, or nil if there is none.
+func (r *Readback) front() *readbackSlot {
+	if s := &r.slots[r.oldest]; s.pending {
+		return s
+	}
+	return nil
+}
+
+// pop retires the oldest pending slot, keeping its pixel buffer for reuse,
+// and returns the function its image is delivered to.
+func (r *Readback) pop() func(*image.RGBA) {
+	s := r.front()
+	if s == nil {
+		log.Panic("Readback: ring underflow")
+	}
+	deliver := s.deliver
+	*s = readbackSlot{pbo: s.pbo}
+	r.oldest = (r.oldest + 1) % len(r.slots)
+	return deliver
+}
+
+// Poll delivers the images of all completed reads, oldest first, without
+// blocking. Returns the number delivered.
+func (r *Readback) Poll() int {
+	n := 0
+	for r.collect(false) {
+		n++
+	}
+	return n
+}
+
+// Flush waits for all pending reads and delivers their images.
+func (r *Readback) Flush() {
+	for r.collect(true) {
+	}
+}
+
+// collect delivers the image of the oldest pending read. If wait is false
+// and the read has not completed, it returns false.
+func (r *Readback) collect(wait bool) bool {
+	s := r.front()
+	if s == nil {
+		return false
+	}
+
+	var timeout uint64
+	if wait {
+		timeout = 1<<64 - 1
+	}
+	switch s.fence.ClientWait(gl.SYNC_FLUSH_COMMANDS_BIT, timeout) {
+	case gl.TIMEOUT_EXPIRED:
+		return false
+	case gl.WAIT_FAILED:
+		log.Panic("Readback: waiting for fence failed")
+	}
+	s.fence.Delete()
+
+	im := image.NewRGBA(image.Rect(0, 0, r.W, r.H))
+	With(BindBuffer{gl.PIXEL_PACK_BUFFER, s.pbo}, func() {
+		p := gl.MapBuffer(gl.PIXEL_PACK_BUFFER, gl.READ_ONLY)
+		if p == nil {
+			log.Panic("Readback: mapping pixel buffer failed")
+		}
+		n := len(im.Pix)
+		copy(im.Pix, (*[1 << 30]byte)(p)[:n:n])
+		gl.UnmapBuffer(gl.PIXEL_PACK_BUFFER)
+	})
+
+	r.pop()(im)
+	return true
+}
+
+// Release deletes the pixel buffer objects and the fences of pending reads,
+// whose images are never delivered.
+func (r *Readback) Release() {
+	for i := range r.slots {
+		s := &r.slots[i]
+		if s.pending {
+			s.fence.Delete()
+		}
+		if s.pbo != 0 {
+			s.pbo.Delete()
+		}
+		r.slots[i] = readbackSlot{}
+	}
+	r.next, r.oldest = 0, 0
+}
@@ -0,0 +1,123 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+func TestReadbackRing(t *testing.T) {
+	r := NewReadback(1, 1, 3)
+	for i := range r.slots {
+		r.slots[i].pbo = gl.Buffer(10 + i)
+	}
+
+	var delivered []int
+	read := func(i int) func(*image.RGBA) {
+		return func(*image.RGBA) { delivered = append(delivered, i) }
+	}
+
+	if r.front() != nil {
+		t.Fatal("new ring has a pending slot")
+	}
+
+	// Reads 0 to 6 through three slots, collecting whenever the ring is full
+	// as issue does, then draining it.
+	for i := 0; i < 7; i++ {
+		if r.slots[r.next].pending {
+			if r.front() != &r.slots[r.next] {
+				t.Fatalf("read %d: full ring, but oldest %d != next %d",
+					i, r.oldest, r.next)
+			}
+			r.pop()(nil)
+		}
+		if r.next != i%3 {
+			t.Fatalf("read %d: Want slot %d, Have %d", i, i%3, r.next)
+		}
+		r.push(read(i))
+	}
+	for r.front() != nil {
+		r.pop()(nil)
+	}
+
+	if want := []int{0, 1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(delivered, want) {
+		t.Errorf("delivered: Want %v, Have %v", want, delivered)
+	}
+	if r.next != 1 || r.oldest != 1 {
+		t.Errorf("indices: Want 1, 1, Have %d, %d", r.next, r.oldest)
+	}
+
+	// Retired slots keep their pixel buffer but forget the fence and the
+	// delivery function.
+	for i, s := range r.slots {
+		if s.pbo != gl.Buffer(10+i) {
+			t.Errorf("slot %d: pbo %v not kept", i, s.pbo)
+		}
+		if s.pending || s.deliver != nil || !reflect.ValueOf(s.fence).IsZero() {
+			t.Errorf("slot %d not cleared: %+v", i, s)
+		}
+	}
+}
+
+func TestReadbackRingPanics(t *testing.T) {
+	tests := []struct {
+		Name string
+		Fn   func(r *Readback)
+	}{
+		{"underflow", func(r *Readback) { r.pop() }},
+		{"overflow", func(r *Readback) {
+			r.push(func(*image.RGBA) {})
+			r.push(func(*image.RGBA) {})
+		}},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", test.Name)
+				}
+			}()
+			test.Fn(NewReadback(1, 1, 1))
+		}()
+	}
+}
+
+// Reads from a ring smaller than the number of reads in flight are all
+// delivered, in order, with the pixels read.
+func TestReadbackDelivery(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		tex := NewTexture(2, 2)
+		tex.InitFormat(gl.RGBA)
+		defer tex.Delete()
+
+		r := NewReadback(2, 2, 2)
+		defer r.Release()
+
+		var got []uint8
+		With(&Framebuffer{Texture: tex}, func() {
+			for i := 0; i < 5; i++ {
+				v := uint8(50 * i)
+				With(ClearColor{gl.GLclampf(v) / 255, 0, 0, 1}, func() {
+					gl.Clear(gl.COLOR_BUFFER_BIT)
+				})
+				r.Read(0, 0, func(im *image.RGBA) { got = append(got, im.Pix[0]) })
+			}
+		})
+		r.Flush()
+
+		if want := []uint8{0, 50, 100, 150, 200}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Want %v, Have %v", want, got)
+		}
+		if r.front() != nil {
+			t.Errorf("reads pending after Flush")
+		}
+	}, func() {})
+}
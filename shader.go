@@ -19,6 +19,9 @@ func (s Shader) Compile() gl.Shader {
 	return MakeShader(s.Type, s.Program)
 }
 
+// NewProgram compiles and links shaders. Use the program with UseProgram,
+// which restores the previously used program on Exit:
+//   `With(UseProgram{NewProgram(shaders...)}, func() { .. draw using the program .. })`
 func NewProgram(shaders ...Shader) gl.Program {
 	program := gl.CreateProgram()
 	for _, shader := range shaders {
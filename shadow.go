@@ -0,0 +1,104 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"math"
+
+	"github.com/go-gl-legacy/glh/glmath"
+	"github.com/go-gl/gl"
+)
+
+// A ShadowMap renders the depth of the scene, as seen from a light, into a
+// depth texture. Set up the light with Directional or Spot, then draw the
+// occluders inside the context. The GL_PROJECTION and GL_MODELVIEW matrices
+// are set to the light's matrices during the context.
+// Example:
+//     sm := NewShadowMap(1024)
+//     sm.Directional(sun, sceneBounds)
+//     With(sm, drawOccluders)
+//     .. bind sm.Depth and upload sm.TextureMatrix() when shading ..
+type ShadowMap struct {
+	Depth            *Texture
+	View, Projection glmath.Mat4
+
+	fb *Framebuffer
+}
+
+// NewShadowMap creates a shadow map with a square depth texture.
+func NewShadowMap(size int) *ShadowMap {
+	depth := NewDepthTexture(size, size)
+	return &ShadowMap{
+		Depth:      depth,
+		View:       glmath.Ident4(),
+		Projection: glmath.Ident4(),
+		fb:         &Framebuffer{Depth: depth},
+	}
+}
+
+// lightUp returns an up vector which is not parallel to dir.
+func lightUp(dir glmath.Vec3) glmath.Vec3 {
+	if math.Abs(dir.Normalize()[1]) > 0.99 {
+		return glmath.Vec3{0, 0, 1}
+	}
+	return glmath.Vec3{0, 1, 0}
+}
+
+// Directional sets up the matrices for a directional light shining along dir,
+// with an orthographic projection fitted tightly around bounds.
+func (s *ShadowMap) Directional(dir glmath.Vec3, bounds glmath.AABB) {
+	dir = dir.Normalize()
+	center := bounds.Center()
+	radius := bounds.Size().Len() / 2
+	eye := center.Sub(dir.Mul(radius))
+	s.View = glmath.LookAt(eye, center, lightUp(dir))
+
+	b := bounds.Transform(s.View)
+	// The light looks down -z, so the nearest point has the largest z.
+	s.Projection = glmath.Ortho(b.Min[0], b.Max[0], b.Min[1], b.Max[1],
+		-b.Max[2], -b.Min[2])
+}
+
+// Spot sets up the matrices for a spot light at pos, shining along dir, with
+// a cone of angle degrees. Near and far bound the depth range.
+func (s *ShadowMap) Spot(pos, dir glmath.Vec3, angle, near, far float64) {
+	s.View = glmath.LookAt(pos, pos.Add(dir), lightUp(dir))
+	s.Projection = glmath.Perspective(angle, 1, near, far)
+}
+
+// Matrix returns the light's view-projection matrix.
+func (s *ShadowMap) Matrix() glmath.Mat4 {
+	return s.Projection.Mul(s.View)
+}
+
+// TextureMatrix returns the matrix which maps world co-ordinates to shadow
+// map texture co-ordinates and depth, all in [0, 1].
+func (s *ShadowMap) TextureMatrix() glmath.Mat4 {
+	bias := glmath.Translate(glmath.Vec3{0.5, 0.5, 0.5}).Mul(
+		glmath.Scale(glmath.Vec3{0.5, 0.5, 0.5}))
+	return bias.Mul(s.Matrix())
+}
+
+func (s *ShadowMap) Enter() {
+	s.fb.Enter()
+	gl.Clear(gl.DEPTH_BUFFER_BIT)
+
+	enterProjection(s.Projection)
+	Matrix{gl.MODELVIEW}.Enter()
+	gl.LoadMatrixd((*[16]float64)(&s.View))
+}
+
+func (s *ShadowMap) Exit() {
+	gl.MatrixMode(gl.MODELVIEW)
+	Matrix{gl.MODELVIEW}.Exit()
+	exitProjection()
+
+	s.fb.Exit()
+}
+
+// Release deletes the depth texture.
+func (s *ShadowMap) Release() {
+	s.Depth.Delete()
+}
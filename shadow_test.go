@@ -0,0 +1,53 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl-legacy/glh/glmath"
+)
+
+func TestShadowMapDirectional(t *testing.T) {
+	bounds := glmath.AABB{Min: glmath.Vec3{-3, 0, -2}, Max: glmath.Vec3{5, 4, 1}}
+	for _, dir := range []glmath.Vec3{{1, -1, 0}, {0, -1, 0}, {0.2, -1, 0.5}} {
+		s := &ShadowMap{}
+		s.Directional(dir, bounds)
+		m := s.Matrix()
+
+		for _, c := range bounds.Corners() {
+			p := m.Transform(c)
+			for i := range p {
+				if p[i] < -1-1e-9 || p[i] > 1+1e-9 {
+					t.Errorf("dir %v: corner %v maps to %v, outside clip space",
+						dir, c, p)
+				}
+			}
+		}
+
+		// Points further along the light direction are deeper.
+		c := bounds.Center()
+		near, far := m.Transform(c.Sub(dir)), m.Transform(c.Add(dir))
+		if near[2] >= far[2] {
+			t.Errorf("dir %v: depth %v not less than %v", dir, near[2], far[2])
+		}
+	}
+}
+
+func TestShadowMapSpot(t *testing.T) {
+	s := &ShadowMap{}
+	pos, dir := glmath.Vec3{1, 2, 3}, glmath.Vec3{0, -1, 0}
+	s.Spot(pos, dir, 60, 1, 10)
+
+	p := s.TextureMatrix().MulVec(pos.Add(dir.Mul(5)).Vec4(1))
+	p = p.Mul(1 / p[3])
+	if math.Abs(p[0]-0.5) > 1e-9 || math.Abs(p[1]-0.5) > 1e-9 {
+		t.Errorf("point on axis maps to %v, want centre", p)
+	}
+	if p[2] <= 0 || p[2] >= 1 {
+		t.Errorf("depth %v outside [0, 1]", p[2])
+	}
+}
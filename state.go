@@ -0,0 +1,253 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"github.com/go-gl/gl"
+)
+
+// The contexts in this file each set a single piece of pipeline state on
+// Enter and restore exactly the previous values on Exit. They work in both
+// compatibility and core profiles, and compose with Compound:
+//     With(Compound(Viewport{0, 0, 64, 64}, DepthFunc{gl.LEQUAL}), func() {
+//         .. draw ..
+//     })
+
+// Sets the viewport.
+type Viewport struct{ X, Y, W, H int }
+
+func (v Viewport) Enter() {
+	var prev [4]int32
+	gl.GetIntegerv(gl.VIEWPORT, prev[:])
+	pushState(prev)
+	gl.Viewport(v.X, v.Y, v.W, v.H)
+}
+
+func (v Viewport) Exit() {
+	prev := popState().([4]int32)
+	gl.Viewport(int(prev[0]), int(prev[1]), int(prev[2]), int(prev[3]))
+}
+
+// Sets the scissor box and enables the scissor test.
+type Scissor struct{ X, Y, W, H int }
+
+type scissorState struct {
+	box     [4]int32
+	enabled bool
+}
+
+func (s Scissor) Enter() {
+	var prev scissorState
+	gl.GetIntegerv(gl.SCISSOR_BOX, prev.box[:])
+	prev.enabled = gl.IsEnabled(gl.SCISSOR_TEST)
+	pushState(prev)
+	gl.Scissor(s.X, s.Y, s.W, s.H)
+	gl.Enable(gl.SCISSOR_TEST)
+}
+
+func (s Scissor) Exit() {
+	prev := popState().(scissorState)
+	gl.Scissor(int(prev.box[0]), int(prev.box[1]),
+		int(prev.box[2]), int(prev.box[3]))
+	setEnabled(gl.SCISSOR_TEST, prev.enabled)
+}
+
+// Sets the blend function. This does not enable gl.BLEND, combine with
+// Enable for that. The separate RGB and alpha functions are restored on Exit.
+type BlendFunc struct{ Src, Dst gl.GLenum }
+
+func (b BlendFunc) Enter() {
+	var prev [4]int32
+	gl.GetIntegerv(gl.BLEND_SRC_RGB, prev[0:1])
+	gl.GetIntegerv(gl.BLEND_DST_RGB, prev[1:2])
+	gl.GetIntegerv(gl.BLEND_SRC_ALPHA, prev[2:3])
+	gl.GetIntegerv(gl.BLEND_DST_ALPHA, prev[3:4])
+	pushState(prev)
+	gl.BlendFunc(b.Src, b.Dst)
+}
+
+func (b BlendFunc) Exit() {
+	prev := popState().([4]int32)
+	gl.BlendFuncSeparate(gl.GLenum(prev[0]), gl.GLenum(prev[1]),
+		gl.GLenum(prev[2]), gl.GLenum(prev[3]))
+}
+
+// Sets the blend equation, e.g. gl.FUNC_ADD or gl.MAX.
+type BlendEquation struct{ Mode gl.GLenum }
+
+func (b BlendEquation) Enter() {
+	var prev [2]int32
+	gl.GetIntegerv(gl.BLEND_EQUATION_RGB, prev[0:1])
+	gl.GetIntegerv(gl.BLEND_EQUATION_ALPHA, prev[1:2])
+	pushState(prev)
+	gl.BlendEquation(b.Mode)
+}
+
+func (b BlendEquation) Exit() {
+	prev := popState().([2]int32)
+	gl.BlendEquationSeparate(gl.GLenum(prev[0]), gl.GLenum(prev[1]))
+}
+
+// Sets the depth comparison function. This does not enable gl.DEPTH_TEST.
+type DepthFunc struct{ Func gl.GLenum }
+
+func (d DepthFunc) Enter() {
+	var prev [1]int32
+	gl.GetIntegerv(gl.DEPTH_FUNC, prev[:])
+	pushState(prev[0])
+	gl.DepthFunc(d.Func)
+}
+
+func (d DepthFunc) Exit() {
+	gl.DepthFunc(gl.GLenum(popState().(int32)))
+}
+
+// Enables or disables writing to the depth buffer.
+type DepthMask struct{ Flag bool }
+
+func (d DepthMask) Enter() {
+	var prev [1]bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, prev[:])
+	pushState(prev[0])
+	gl.DepthMask(d.Flag)
+}
+
+func (d DepthMask) Exit() {
+	gl.DepthMask(popState().(bool))
+}
+
+// Sets the stencil test function, reference value and mask for both faces.
+// This does not enable gl.STENCIL_TEST. The front and back face state is
+// restored separately on Exit.
+type StencilFunc struct {
+	Func gl.GLenum
+	Ref  int
+	Mask uint
+}
+
+func (s StencilFunc) Enter() {
+	var prev [6]int32
+	gl.GetIntegerv(gl.STENCIL_FUNC, prev[0:1])
+	gl.GetIntegerv(gl.STENCIL_REF, prev[1:2])
+	gl.GetIntegerv(gl.STENCIL_VALUE_MASK, prev[2:3])
+	gl.GetIntegerv(gl.STENCIL_BACK_FUNC, prev[3:4])
+	gl.GetIntegerv(gl.STENCIL_BACK_REF, prev[4:5])
+	gl.GetIntegerv(gl.STENCIL_BACK_VALUE_MASK, prev[5:6])
+	pushState(prev)
+	gl.StencilFunc(s.Func, s.Ref, s.Mask)
+}
+
+func (s StencilFunc) Exit() {
+	prev := popState().([6]int32)
+	gl.StencilFuncSeparate(gl.FRONT, gl.GLenum(prev[0]), int(prev[1]),
+		uint(uint32(prev[2])))
+	gl.StencilFuncSeparate(gl.BACK, gl.GLenum(prev[3]), int(prev[4]),
+		uint(uint32(prev[5])))
+}
+
+// Sets the stencil operations for both faces. The front and back face
+// operations are restored separately on Exit.
+type StencilOp struct{ Fail, ZFail, ZPass gl.GLenum }
+
+func (s StencilOp) Enter() {
+	var prev [6]int32
+	gl.GetIntegerv(gl.STENCIL_FAIL, prev[0:1])
+	gl.GetIntegerv(gl.STENCIL_PASS_DEPTH_FAIL, prev[1:2])
+	gl.GetIntegerv(gl.STENCIL_PASS_DEPTH_PASS, prev[2:3])
+	gl.GetIntegerv(gl.STENCIL_BACK_FAIL, prev[3:4])
+	gl.GetIntegerv(gl.STENCIL_BACK_PASS_DEPTH_FAIL, prev[4:5])
+	gl.GetIntegerv(gl.STENCIL_BACK_PASS_DEPTH_PASS, prev[5:6])
+	pushState(prev)
+	gl.StencilOp(s.Fail, s.ZFail, s.ZPass)
+}
+
+func (s StencilOp) Exit() {
+	prev := popState().([6]int32)
+	gl.StencilOpSeparate(gl.FRONT, gl.GLenum(prev[0]), gl.GLenum(prev[1]),
+		gl.GLenum(prev[2]))
+	gl.StencilOpSeparate(gl.BACK, gl.GLenum(prev[3]), gl.GLenum(prev[4]),
+		gl.GLenum(prev[5]))
+}
+
+// Enables or disables writing of the individual color channels.
+type ColorMask struct{ R, G, B, A bool }
+
+func (c ColorMask) Enter() {
+	var prev [4]bool
+	gl.GetBooleanv(gl.COLOR_WRITEMASK, prev[:])
+	pushState(prev)
+	gl.ColorMask(c.R, c.G, c.B, c.A)
+}
+
+func (c ColorMask) Exit() {
+	prev := popState().([4]bool)
+	gl.ColorMask(prev[0], prev[1], prev[2], prev[3])
+}
+
+// Sets the polygon rasterization mode, e.g.
+//     PolygonMode{gl.FRONT_AND_BACK, gl.LINE}
+type PolygonMode struct{ Face, Mode gl.GLenum }
+
+func (p PolygonMode) Enter() {
+	// Yields the front and back modes.
+	var prev [2]int32
+	gl.GetIntegerv(gl.POLYGON_MODE, prev[:])
+	pushState(prev)
+	gl.PolygonMode(p.Face, p.Mode)
+}
+
+func (p PolygonMode) Exit() {
+	prev := popState().([2]int32)
+	if prev[0] == prev[1] {
+		gl.PolygonMode(gl.FRONT_AND_BACK, gl.GLenum(prev[0]))
+		return
+	}
+	gl.PolygonMode(gl.FRONT, gl.GLenum(prev[0]))
+	gl.PolygonMode(gl.BACK, gl.GLenum(prev[1]))
+}
+
+// Sets the rasterized line width.
+type LineWidth struct{ Width float32 }
+
+func (l LineWidth) Enter() {
+	var prev [1]float32
+	gl.GetFloatv(gl.LINE_WIDTH, prev[:])
+	pushState(prev[0])
+	gl.LineWidth(l.Width)
+}
+
+func (l LineWidth) Exit() {
+	gl.LineWidth(popState().(float32))
+}
+
+// Sets the rasterized point size.
+type PointSize struct{ Size float32 }
+
+func (p PointSize) Enter() {
+	var prev [1]float32
+	gl.GetFloatv(gl.POINT_SIZE, prev[:])
+	pushState(prev[0])
+	gl.PointSize(p.Size)
+}
+
+func (p PointSize) Exit() {
+	gl.PointSize(popState().(float32))
+}
+
+// Sets the color used by gl.Clear.
+type ClearColor struct{ R, G, B, A gl.GLclampf }
+
+func (c ClearColor) Enter() {
+	var prev [4]float32
+	gl.GetFloatv(gl.COLOR_CLEAR_VALUE, prev[:])
+	pushState(prev)
+	gl.ClearColor(c.R, c.G, c.B, c.A)
+}
+
+func (c ClearColor) Exit() {
+	prev := popState().([4]float32)
+	gl.ClearColor(gl.GLclampf(prev[0]), gl.GLclampf(prev[1]),
+		gl.GLclampf(prev[2]), gl.GLclampf(prev[3]))
+}
@@ -0,0 +1,114 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+// stateSnapshot queries everything the contexts in state.go touch.
+type stateSnapshot struct {
+	Viewport, Scissor  [4]int32
+	ScissorTest        bool
+	Blend              [4]int32
+	BlendEquation      [2]int32
+	DepthFunc          int32
+	DepthMask          bool
+	Stencil, StencilOp [6]int32
+	ColorMask          [4]bool
+	LineWidth          float32
+	ClearColor         [4]float32
+}
+
+func queryState() (s stateSnapshot) {
+	ints := func(dst []int32, pnames ...gl.GLenum) {
+		for i, pname := range pnames {
+			gl.GetIntegerv(pname, dst[i:i+1])
+		}
+	}
+	gl.GetIntegerv(gl.VIEWPORT, s.Viewport[:])
+	gl.GetIntegerv(gl.SCISSOR_BOX, s.Scissor[:])
+	s.ScissorTest = gl.IsEnabled(gl.SCISSOR_TEST)
+	ints(s.Blend[:], gl.BLEND_SRC_RGB, gl.BLEND_DST_RGB,
+		gl.BLEND_SRC_ALPHA, gl.BLEND_DST_ALPHA)
+	ints(s.BlendEquation[:], gl.BLEND_EQUATION_RGB, gl.BLEND_EQUATION_ALPHA)
+	var depth [1]int32
+	gl.GetIntegerv(gl.DEPTH_FUNC, depth[:])
+	s.DepthFunc = depth[0]
+	var mask [1]bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, mask[:])
+	s.DepthMask = mask[0]
+	ints(s.Stencil[:], gl.STENCIL_FUNC, gl.STENCIL_REF, gl.STENCIL_VALUE_MASK,
+		gl.STENCIL_BACK_FUNC, gl.STENCIL_BACK_REF, gl.STENCIL_BACK_VALUE_MASK)
+	ints(s.StencilOp[:], gl.STENCIL_FAIL, gl.STENCIL_PASS_DEPTH_FAIL,
+		gl.STENCIL_PASS_DEPTH_PASS, gl.STENCIL_BACK_FAIL,
+		gl.STENCIL_BACK_PASS_DEPTH_FAIL, gl.STENCIL_BACK_PASS_DEPTH_PASS)
+	gl.GetBooleanv(gl.COLOR_WRITEMASK, s.ColorMask[:])
+	var width [1]float32
+	gl.GetFloatv(gl.LINE_WIDTH, width[:])
+	s.LineWidth = width[0]
+	gl.GetFloatv(gl.COLOR_CLEAR_VALUE, s.ClearColor[:])
+	return s
+}
+
+// Every state context restores exactly what was there before, including
+// separate blend functions and separate front and back stencil state which
+// the contexts themselves only set for both.
+func TestStateContexts(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		gl.Viewport(1, 2, 3, 4)
+		gl.Scissor(5, 6, 7, 8)
+		gl.BlendFuncSeparate(gl.ONE, gl.ZERO, gl.SRC_ALPHA, gl.ONE)
+		gl.BlendEquationSeparate(gl.FUNC_ADD, gl.MAX)
+		gl.DepthFunc(gl.GREATER)
+		gl.StencilFuncSeparate(gl.FRONT, gl.EQUAL, 1, 0x0f)
+		gl.StencilFuncSeparate(gl.BACK, gl.NOTEQUAL, 2, 0xf0)
+		gl.StencilOpSeparate(gl.FRONT, gl.KEEP, gl.INCR, gl.DECR)
+		gl.StencilOpSeparate(gl.BACK, gl.ZERO, gl.INVERT, gl.REPLACE)
+		gl.LineWidth(2)
+		gl.ClearColor(0.25, 0.5, 0.75, 1)
+		before := queryState()
+
+		contexts := Compound(
+			Viewport{10, 20, 30, 40},
+			Scissor{1, 1, 2, 2},
+			BlendFunc{gl.DST_COLOR, gl.ONE_MINUS_SRC_ALPHA},
+			BlendEquation{gl.MIN},
+			DepthFunc{gl.LEQUAL},
+			DepthMask{false},
+			StencilFunc{gl.ALWAYS, 3, 0xff},
+			StencilOp{gl.REPLACE, gl.REPLACE, gl.REPLACE},
+			ColorMask{false, true, false, true},
+			LineWidth{1},
+			ClearColor{1, 0, 0, 1},
+		)
+
+		With(contexts, func() {
+			inside := queryState()
+			if inside.Viewport != [4]int32{10, 20, 30, 40} || !inside.ScissorTest {
+				t.Errorf("Enter: viewport %v, scissor test %v",
+					inside.Viewport, inside.ScissorTest)
+			}
+			if inside.Stencil[0] != int32(gl.ALWAYS) || inside.Stencil[3] != int32(gl.ALWAYS) {
+				t.Errorf("Enter: stencil %v", inside.Stencil)
+			}
+			if inside.DepthMask || inside.ColorMask != [4]bool{false, true, false, true} {
+				t.Errorf("Enter: depth mask %v, color mask %v",
+					inside.DepthMask, inside.ColorMask)
+			}
+		})
+
+		if after := queryState(); !reflect.DeepEqual(after, before) {
+			t.Errorf("state not restored:\nWant %+v\nHave %+v", before, after)
+		}
+		if len(savedState) != 0 {
+			t.Errorf("%d saved states left over", len(savedState))
+		}
+	}, func() {})
+}
@@ -36,14 +36,82 @@ func NewTexture(w, h int) *Texture {
 
 // Initialize texture storage. _REQUIRED_ before using it as a framebuffer target.
 func (t *Texture) Init() {
+	t.InitFormat(gl.RGBA)
+}
+
+// Initialize texture storage with the given internal format, such as
+// gl.RGBA16F for high dynamic range rendering. Depth, depth-stencil and
+// integer formats are supported too; mipmaps are only generated for the
+// other formats, which can be filtered.
+func (t *Texture) InitFormat(internalformat int) {
+	format, typ, mipmaps := storageFormat(gl.GLenum(internalformat))
 	With(t, func() {
 		// generate base level storage
-		gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, t.W, t.H, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
-		// generate required number of mipmaps given texture dimensions
-		gl.GenerateMipmap(gl.TEXTURE_2D)
+		gl.TexImage2D(gl.TEXTURE_2D, 0, internalformat, t.W, t.H, 0, format, typ, nil)
+		if mipmaps {
+			// generate required number of mipmaps given texture dimensions
+			gl.GenerateMipmap(gl.TEXTURE_2D)
+		}
 	})
 }
 
+// storageFormat returns a pixel format and type compatible with the given
+// internal format, to allocate storage without data, and whether mipmaps
+// can be generated for it.
+func storageFormat(internalformat gl.GLenum) (format, typ gl.GLenum, mipmaps bool) {
+	switch internalformat {
+	case gl.DEPTH_COMPONENT, gl.DEPTH_COMPONENT16, gl.DEPTH_COMPONENT24,
+		gl.DEPTH_COMPONENT32:
+		return gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, false
+	case gl.DEPTH_COMPONENT32F:
+		return gl.DEPTH_COMPONENT, gl.FLOAT, false
+	case gl.DEPTH_STENCIL, gl.DEPTH24_STENCIL8:
+		return gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8, false
+	case gl.DEPTH32F_STENCIL8:
+		return gl.DEPTH_STENCIL, gl.FLOAT_32_UNSIGNED_INT_24_8_REV, false
+	case gl.RGB10_A2UI:
+		return gl.RGBA_INTEGER, gl.UNSIGNED_INT_2_10_10_10_REV, false
+	}
+
+	typ = gl.INT
+	switch formatClass(internalformat) {
+	case formatNormalized:
+		return gl.RGBA, gl.UNSIGNED_BYTE, true
+	case formatUnsigned:
+		typ = gl.UNSIGNED_INT
+	}
+
+	switch internalformat {
+	case gl.R8I, gl.R16I, gl.R32I, gl.R8UI, gl.R16UI, gl.R32UI:
+		return gl.RED_INTEGER, typ, false
+	case gl.RG8I, gl.RG16I, gl.RG32I, gl.RG8UI, gl.RG16UI, gl.RG32UI:
+		return gl.RG_INTEGER, typ, false
+	case gl.RGB8I, gl.RGB16I, gl.RGB32I, gl.RGB8UI, gl.RGB16UI, gl.RGB32UI:
+		return gl.RGB_INTEGER, typ, false
+	}
+	return gl.RGBA_INTEGER, typ, false
+}
+
+// Create a depth texture with initialized storage, for use as the Depth of a
+// Framebuffer. Comparison mode is enabled with gl.LEQUAL, so the texture can
+// be sampled with a sampler2DShadow for shadow mapping. Do not call Init on
+// it; that would replace the storage with a color format.
+func NewDepthTexture(w, h int) *Texture {
+	texture := &Texture{gl.GenTexture(), w, h}
+	With(texture, func() {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_COMPARE_MODE,
+			gl.COMPARE_REF_TO_TEXTURE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_COMPARE_FUNC, gl.LEQUAL)
+		gl.TexImage2D(gl.TEXTURE_2D, 0, gl.DEPTH_COMPONENT24, w, h, 0,
+			gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+	})
+	return texture
+}
+
 func (b Texture) Enter() {
 	gl.PushAttrib(gl.ENABLE_BIT)
 	gl.Enable(gl.TEXTURE_2D)
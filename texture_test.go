@@ -0,0 +1,38 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl"
+)
+
+func TestStorageFormat(t *testing.T) {
+	tests := []struct {
+		In      gl.GLenum
+		Format  gl.GLenum
+		Type    gl.GLenum
+		Mipmaps bool
+	}{
+		{gl.RGBA, gl.RGBA, gl.UNSIGNED_BYTE, true},
+		{gl.RGBA16F, gl.RGBA, gl.UNSIGNED_BYTE, true},
+		{gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, false},
+		{gl.DEPTH_COMPONENT32F, gl.DEPTH_COMPONENT, gl.FLOAT, false},
+		{gl.DEPTH24_STENCIL8, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8, false},
+		{gl.R32UI, gl.RED_INTEGER, gl.UNSIGNED_INT, false},
+		{gl.RG16I, gl.RG_INTEGER, gl.INT, false},
+		{gl.RGBA8UI, gl.RGBA_INTEGER, gl.UNSIGNED_INT, false},
+	}
+
+	for _, tt := range tests {
+		format, typ, mipmaps := storageFormat(tt.In)
+		if format != tt.Format || typ != tt.Type || mipmaps != tt.Mipmaps {
+			t.Errorf("storageFormat(%s) = %x, %x, %v; want %x, %x, %v",
+				enumName(formatNames, tt.In), format, typ, mipmaps,
+				tt.Format, tt.Type, tt.Mipmaps)
+		}
+	}
+}
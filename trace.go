@@ -0,0 +1,157 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/go-gl/gl"
+)
+
+// When TraceContexts is true, `With` records a stack of active contexts and
+// verifies that the attrib, client attrib and matrix stack depths are the
+// same after a context's Exit as they were before its Enter. If they are not,
+// `With` panics with a *ContextImbalanceError naming the offending context.
+// This includes a panic which skipped an Exit; the error carries the original
+// panic value.
+//
+// This is a debugging aid: every `With` performs a number of glGet calls.
+var TraceContexts = false
+
+// The names of the contexts currently entered through `With`, outermost first.
+var activeContexts []string
+
+// ActiveContexts returns the names of the contexts currently entered through
+// `With`, outermost first. Only maintained while TraceContexts is true.
+func ActiveContexts() []string {
+	return append([]string(nil), activeContexts...)
+}
+
+// Gives a context a name for use in traces.
+// Example:
+//     With(Named("shadow pass", &Framebuffer{Texture: shadow}), draw)
+func Named(name string, c Context) Context {
+	return namedContext{c, name}
+}
+
+type namedContext struct {
+	Context
+	name string
+}
+
+func (n namedContext) String() string { return n.name }
+
+// contextName returns the name under which c appears in traces.
+func contextName(c Context) string {
+	if s, ok := c.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", c)
+}
+
+// StackDepths holds the depths of the OpenGL and glh state stacks.
+type StackDepths struct {
+	Attrib       int // gl.ATTRIB_STACK_DEPTH
+	ClientAttrib int // gl.CLIENT_ATTRIB_STACK_DEPTH
+	ModelView    int // gl.MODELVIEW_STACK_DEPTH
+	Projection   int // gl.PROJECTION_STACK_DEPTH
+	Texture      int // gl.TEXTURE_STACK_DEPTH
+
+	CoreModelView  int // Depth of glh.ModelView.
+	CoreProjection int // Depth of glh.Projection.
+	SavedState     int // Values saved by query-based contexts.
+}
+
+// GetStackDepths returns the current stack depths. Stacks which do not exist
+// in the current context, like the fixed-function stacks in a core profile,
+// are reported as -1.
+//
+// This clears the OpenGL error flag.
+func GetStackDepths() StackDepths {
+	get := func(pname gl.GLenum) int {
+		var v [1]int32
+		gl.GetIntegerv(pname, v[:])
+		if gl.GetError() != gl.NO_ERROR {
+			return -1
+		}
+		return int(v[0])
+	}
+	return StackDepths{
+		Attrib:         get(gl.ATTRIB_STACK_DEPTH),
+		ClientAttrib:   get(gl.CLIENT_ATTRIB_STACK_DEPTH),
+		ModelView:      get(gl.MODELVIEW_STACK_DEPTH),
+		Projection:     get(gl.PROJECTION_STACK_DEPTH),
+		Texture:        get(gl.TEXTURE_STACK_DEPTH),
+		CoreModelView:  ModelView.Depth(),
+		CoreProjection: Projection.Depth(),
+		SavedState:     len(savedState),
+	}
+}
+
+// ContextImbalanceError describes a context whose Exit did not undo the
+// stack changes made by its Enter, or whose Exit was skipped by a panic.
+type ContextImbalanceError struct {
+	Context string      // Name of the offending context.
+	Active  []string    // Active contexts, outermost first, including Context.
+	Before  StackDepths // Depths before Enter.
+	After   StackDepths // Depths after Exit.
+	Stack   string      // Go stack trace at the time of Exit.
+	Panic   interface{} // The panic unwinding through the context, if any.
+}
+
+func (e *ContextImbalanceError) Error() string {
+	msg := fmt.Sprintf("glh: context %s left unbalanced stacks: "+
+		"before %+v, after %+v\nactive contexts: %s\n%s",
+		e.Context, e.Before, e.After, strings.Join(e.Active, " > "), e.Stack)
+	if e.Panic != nil {
+		msg = fmt.Sprintf("%s\nwhile panicking: %v", msg, e.Panic)
+	}
+	return msg
+}
+
+// traceContext records c as active and returns a function which verifies
+// the stack depths and removes c again. Used by `With`, which must defer
+// the returned function so that it can recover a panic in progress.
+func traceContext(c Context) func() {
+	before := GetStackDepths()
+	activeContexts = append(activeContexts, contextName(c))
+	n := len(activeContexts)
+
+	return func() {
+		active := activeContexts[:n]
+		activeContexts = activeContexts[:n-1]
+
+		r := recover()
+		if _, ok := r.(*ContextImbalanceError); ok {
+			// Already reported by an inner context, whose imbalance
+			// this one inherits.
+			panic(r)
+		}
+		if r == nil {
+			// Report genuine errors before GetStackDepths clears them.
+			OpenGLSentinel()
+		}
+
+		// A panic which skipped an Exit leaves the stacks unbalanced for
+		// every later `With`, so it is reported too, carrying the
+		// original panic. Balanced panics pass through unchanged.
+		after := GetStackDepths()
+		if after != before {
+			panic(&ContextImbalanceError{
+				Context: active[n-1],
+				Active:  append([]string(nil), active...),
+				Before:  before,
+				After:   after,
+				Stack:   string(debug.Stack()),
+				Panic:   r,
+			})
+		}
+		if r != nil {
+			panic(r)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/go-gl/testutils"
+)
+
+// panicEnter panics in Enter after pushing a matrix, so Exit never runs and
+// the stacks are unbalanced.
+type panicEnter struct{}
+
+func (panicEnter) Enter() {
+	ModelView.Push()
+	panic("boom")
+}
+func (panicEnter) Exit() {}
+
+// leakyContext pushes a matrix in Enter which its Exit does not pop.
+type leakyContext struct{}
+
+func (leakyContext) Enter() { ModelView.Push() }
+func (leakyContext) Exit()  {}
+
+// tracePanic runs fn with TraceContexts enabled and returns what it panicked
+// with. It checks that no contexts are left active.
+func tracePanic(t *testing.T, fn func()) (r interface{}) {
+	TraceContexts = true
+	defer func() {
+		TraceContexts = false
+		r = recover()
+		if n := len(ActiveContexts()); n != 0 {
+			t.Errorf("%d contexts still active", n)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// A panic inside a traced context whose Exit runs must reach the caller
+// unchanged.
+func TestTraceContextsPanic(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		r := tracePanic(t, func() {
+			With(Named("outer", CoreMatrix{gl.MODELVIEW}), func() {
+				panic("boom")
+			})
+		})
+		if r != "boom" {
+			t.Errorf("recovered %v, want boom", r)
+		}
+	}, func() {})
+}
+
+// A context which does not pop what it pushed is reported, naming it and
+// the contexts around it.
+func TestTraceContextsImbalance(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		depth := ModelView.Depth()
+		r := tracePanic(t, func() {
+			With(Named("outer", CoreMatrix{gl.PROJECTION}), func() {
+				With(Named("leaky", leakyContext{}), func() {})
+			})
+		})
+		ModelView.Pop()
+
+		err, ok := r.(*ContextImbalanceError)
+		if !ok {
+			t.Fatalf("recovered %v, want *ContextImbalanceError", r)
+		}
+		if err.Context != "leaky" {
+			t.Errorf("Context: Want leaky, Have %q", err.Context)
+		}
+		if want := []string{"outer", "leaky"}; !reflect.DeepEqual(err.Active, want) {
+			t.Errorf("Active: Want %v, Have %v", want, err.Active)
+		}
+		if err.Before.CoreModelView != depth || err.After.CoreModelView != depth+1 {
+			t.Errorf("depths: before %d, after %d",
+				err.Before.CoreModelView, err.After.CoreModelView)
+		}
+		if err.Panic != nil {
+			t.Errorf("Panic: Want nil, Have %v", err.Panic)
+		}
+	}, func() {})
+}
+
+// A panic which skips an Exit is reported as an imbalance carrying the
+// original panic, rather than leaving later contexts to start from drifted
+// depths.
+func TestTraceContextsPanicImbalance(t *testing.T) {
+	gltest.OnTheMainThread(func() {
+		r := tracePanic(t, func() {
+			With(Named("outer", CoreMatrix{gl.PROJECTION}), func() {
+				With(Named("enter", panicEnter{}), func() {})
+			})
+		})
+		ModelView.Pop()
+
+		err, ok := r.(*ContextImbalanceError)
+		if !ok {
+			t.Fatalf("recovered %v, want *ContextImbalanceError", r)
+		}
+		if err.Context != "enter" || err.Panic != "boom" {
+			t.Errorf("Context %q, Panic %v; want enter, boom", err.Context, err.Panic)
+		}
+		if want := []string{"outer", "enter"}; !reflect.DeepEqual(err.Active, want) {
+			t.Errorf("Active: Want %v, Have %v", want, err.Active)
+		}
+	}, func() {})
+}
@@ -5,13 +5,16 @@
 package glh
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
 	"log"
 	"os"
+	"strings"
 	"unsafe"
 
+	"github.com/go-gl-legacy/glh/glmath"
 	"github.com/go-gl/gl"
 	"github.com/go-gl/glu"
 )
@@ -75,6 +78,32 @@ func OpenGLSentinel() func() {
 	return check
 }
 
+// Returns the major and minor version of the current OpenGL context.
+func GLVersion() (major, minor int) {
+	return parseGLVersion(gl.GetString(gl.VERSION))
+}
+
+// parseGLVersion parses a GL_VERSION string, such as "2.1 Mesa 10.1" or
+// "OpenGL ES 3.0 build 1.9".
+func parseGLVersion(s string) (major, minor int) {
+	if i := strings.IndexAny(s, "0123456789"); i >= 0 {
+		fmt.Sscanf(s[i:], "%d.%d", &major, &minor)
+	}
+	return major, minor
+}
+
+// Returns true if the current OpenGL context supports the named extension,
+// such as "GL_ARB_vertex_array_object". This uses the extension string,
+// which is unavailable in core profiles.
+func HasExtension(name string) bool {
+	for _, e := range strings.Fields(gl.GetString(gl.EXTENSIONS)) {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Returns w, h of viewport
 func GetViewportWH() (int, int) {
 	var viewport [4]int32
@@ -89,36 +118,51 @@ func GetViewportWHD() (float64, float64) {
 
 // Returns x, y in window co-ordinates at 0 in the z direction
 func WindowToProj(x, y int) (float64, float64) {
-	var projmat, modelmat [16]float64
-	var viewport [4]int32
+	modelview, projection, viewport := getMatrices()
+	return WindowToProjWith(float64(x), float64(y), modelview, projection, viewport)
+}
 
-	gl.GetDoublev(gl.PROJECTION_MATRIX, projmat[:])
-	gl.GetDoublev(gl.MODELVIEW_MATRIX, modelmat[:])
+// Same as WindowToProj, using the given matrices and viewport instead of the
+// current OpenGL state. Does not require an OpenGL context.
+func WindowToProjWith(x, y float64, modelview, projection glmath.Mat4,
+	viewport [4]int) (float64, float64) {
 
-	gl.GetIntegerv(gl.VIEWPORT, viewport[:])
 	// Need to convert so that y is at lower left
-	y = int(viewport[3]) - y
-
-	px, py, _ := glu.UnProject(float64(x), float64(y), 0,
-		&modelmat, &projmat, &viewport)
+	y = float64(viewport[3]) - y
 
-	return px, py
+	p, _ := glmath.UnProject(glmath.Vec3{x, y, 0}, modelview, projection,
+		viewport)
+	return p[0], p[1]
 }
 
 // Returns x, y in window co-ordinates at 0 in the z direction
 func ProjToWindow(x, y float64) (float64, float64) {
-	var projmat, modelmat [16]float64
-	var viewport [4]int32
+	modelview, projection, viewport := getMatrices()
+	return ProjToWindowWith(x, y, modelview, projection, viewport)
+}
 
-	gl.GetDoublev(gl.PROJECTION_MATRIX, projmat[:])
-	gl.GetDoublev(gl.MODELVIEW_MATRIX, modelmat[:])
-	gl.GetIntegerv(gl.VIEWPORT, viewport[:])
+// Same as ProjToWindow, using the given matrices and viewport instead of the
+// current OpenGL state. Does not require an OpenGL context.
+func ProjToWindowWith(x, y float64, modelview, projection glmath.Mat4,
+	viewport [4]int) (float64, float64) {
+
+	p := glmath.Project(glmath.Vec3{x, y, 0}, modelview, projection, viewport)
+	return p[0], float64(viewport[3]) - p[1]
+}
 
-	px, py, _ := glu.Project(float64(x), float64(y), 0,
-		&modelmat, &projmat, &viewport)
+// getMatrices reads back the fixed-function modelview and projection
+// matrices and the viewport.
+func getMatrices() (modelview, projection glmath.Mat4, viewport [4]int) {
+	var vp [4]int32
 
-	//return int(px), int(viewport[3]) - int(py)
-	return px, float64(viewport[3]) - py
+	gl.GetDoublev(gl.MODELVIEW_MATRIX, modelview[:])
+	gl.GetDoublev(gl.PROJECTION_MATRIX, projection[:])
+	gl.GetIntegerv(gl.VIEWPORT, vp[:])
+
+	for i := range vp {
+		viewport[i] = int(vp[i])
+	}
+	return
 }
 
 // Draws lines of unit length along the X, Y, Z axis in R, G, B
@@ -0,0 +1,28 @@
+// Copyright 2012 The go-gl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glh
+
+import "testing"
+
+func TestParseGLVersion(t *testing.T) {
+	tests := []struct {
+		In           string
+		Major, Minor int
+	}{
+		{"2.1 Mesa 10.1.3", 2, 1},
+		{"3.3.0 NVIDIA 331.38", 3, 3},
+		{"4.1 ATI-1.20.11", 4, 1},
+		{"OpenGL ES 3.0 build 1.9", 3, 0},
+		{"", 0, 0},
+	}
+
+	for _, tt := range tests {
+		major, minor := parseGLVersion(tt.In)
+		if major != tt.Major || minor != tt.Minor {
+			t.Errorf("parseGLVersion(%q) = %d.%d, want %d.%d",
+				tt.In, major, minor, tt.Major, tt.Minor)
+		}
+	}
+}